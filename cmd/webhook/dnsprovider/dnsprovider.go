@@ -15,7 +15,7 @@ import (
 
 type PiholeProviderFactory func(baseProvider *provider.BaseProvider, piholeConfig *pihole.Config) provider.Provider
 
-func Init(config configuration.Config) (provider.Provider, error) {
+func Init(config configuration.Config) (*pihole.PiholeProvider, error) {
 	var domainFilter endpoint.DomainFilter
 	createMsg := "creating pihole provider with "
 
@@ -31,5 +31,5 @@ func main() {
 	}
 
 	main, health := server.Init(config, webhook.New(provider))
-	server.ShutdownGracefully(main, health)
+	server.ShutdownGracefully(main, health, provider)
 }
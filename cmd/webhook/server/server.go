@@ -26,9 +26,16 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // ReadinessHandler returns whether the service is ready to accept requests
-func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+func ReadinessHandler(p *webhook.Webhook) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !p.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("NOT READY"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
 }
 
 // Init initializes the http server
@@ -50,7 +57,7 @@ func Init(config configuration.Config, p *webhook.Webhook) (*http.Server, *http.
 	healthRouter := chi.NewRouter()
 	healthRouter.Get("/metrics", promhttp.Handler().ServeHTTP)
 	healthRouter.Get("/healthz", HealthCheckHandler)
-	healthRouter.Get("/readyz", ReadinessHandler)
+	healthRouter.Get("/readyz", ReadinessHandler(p))
 
 	healthServer := createHTTPServer("0.0.0.0:8080", healthRouter, config.ServerReadTimeout, config.ServerWriteTimeout)
 	go func() {
@@ -72,8 +79,14 @@ func createHTTPServer(addr string, hand http.Handler, readTimeout, writeTimeout
 	}
 }
 
+// Closer releases resources held by the webhook, such as its Pi-hole session, once the servers
+// are shut down.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
 // ShutdownGracefully gracefully shutdown the http server
-func ShutdownGracefully(mainServer *http.Server, healthServer *http.Server) {
+func ShutdownGracefully(mainServer *http.Server, healthServer *http.Server, closers ...Closer) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	sig := <-sigCh
@@ -89,4 +102,10 @@ func ShutdownGracefully(mainServer *http.Server, healthServer *http.Server) {
 	if err := healthServer.Shutdown(ctx); err != nil {
 		log.Error("error shutting down health server", zap.Error(err))
 	}
+
+	for _, closer := range closers {
+		if err := closer.Close(ctx); err != nil {
+			log.Error("error releasing resources", zap.Error(err))
+		}
+	}
 }
@@ -11,7 +11,7 @@ import (
 func (suite *PiholeTestSuite) TestARecordsEndpoint() {
 	t := suite.T()
 	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
-		assert.Equal(t, "/api/config/dns/hosts", r.URL.Path)
+		assert.Equal(t, "/api/config", r.URL.Path)
 	})
 	defer server.Close()
 
@@ -20,7 +20,7 @@ func (suite *PiholeTestSuite) TestARecordsEndpoint() {
 		Password: "password",
 	})
 
-	_, _ = client.listRecords(context.Background(), "A")
+	_, _ = client.listRecordsOfTypes(context.Background(), "A")
 }
 
 func (suite *PiholeTestSuite) TestListAEndpoints() {
@@ -54,7 +54,7 @@ func (suite *PiholeTestSuite) TestListAEndpoints() {
 		t.Fatal(err)
 	}
 
-	records, err := cl.listRecords(context.Background(), "A")
+	records, err := cl.listRecordsOfTypes(context.Background(), "A")
 
 	assert.Len(t, records, 1)
 	assert.Equal(t, records[0].DNSName, "test-one.example.io")
@@ -79,3 +79,66 @@ func (suite *PiholeTestSuite) TestCreateARecord() {
 		RecordType: endpoint.RecordTypeA,
 	})
 }
+
+func (suite *PiholeTestSuite) TestListAEndpointsGroupsTargets() {
+	t := suite.T()
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(RecordsResponse{
+			Config: RecordsConfig{
+				DNS: DNS{
+					Hosts: []string{
+						"1.1.1.1 test-one.example.io",
+						"2.2.2.2 test-two.example.io",
+						"3.3.3.3 test-one.example.io",
+						"3.3.3.3 test-one.example.io",
+					},
+				},
+			},
+		})
+	})
+	defer server.Close()
+
+	cl, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "correct",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := cl.listRecordsOfTypes(context.Background(), "A")
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "test-one.example.io", records[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"1.1.1.1", "3.3.3.3"}, records[0].Targets)
+	assert.Equal(t, "test-two.example.io", records[1].DNSName)
+	assert.Equal(t, endpoint.Targets{"2.2.2.2"}, records[1].Targets)
+}
+
+func (suite *PiholeTestSuite) TestCreateMultiTargetARecord() {
+	t := suite.T()
+	var paths []string
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		paths = append(paths, r.URL.Path)
+	})
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"1.1.1.1", "2.2.2.2"},
+		DNSName:    "test-one.example.io",
+		RecordType: endpoint.RecordTypeA,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"/api/config/dns/hosts/1.1.1.1 test-one.example.io",
+		"/api/config/dns/hosts/2.2.2.2 test-one.example.io",
+	}, paths)
+}
@@ -11,7 +11,7 @@ import (
 func (suite *PiholeTestSuite) TestAAAARecordsEndpoint() {
 	t := suite.T()
 	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
-		assert.Equal(t, "/api/config/dns/hosts", r.URL.Path)
+		assert.Equal(t, "/api/config", r.URL.Path)
 	})
 	defer server.Close()
 
@@ -20,7 +20,7 @@ func (suite *PiholeTestSuite) TestAAAARecordsEndpoint() {
 		Password: "password",
 	})
 
-	_, _ = client.listRecords(context.Background(), "AAAA")
+	_, _ = client.listRecordsOfTypes(context.Background(), "AAAA")
 }
 
 func (suite *PiholeTestSuite) TestListAAAAEndpoints() {
@@ -54,7 +54,7 @@ func (suite *PiholeTestSuite) TestListAAAAEndpoints() {
 		t.Fatal(err)
 	}
 
-	records, err := cl.listRecords(context.Background(), "AAAA")
+	records, err := cl.listRecordsOfTypes(context.Background(), "AAAA")
 
 	assert.Len(t, records, 1)
 	assert.Equal(t, records[0].DNSName, "test-three.example.io")
@@ -79,3 +79,55 @@ func (suite *PiholeTestSuite) TestCreateAAAARecord() {
 		RecordType: endpoint.RecordTypeAAAA,
 	})
 }
+
+func (suite *PiholeTestSuite) TestRecordsReadsConfigOnce() {
+	t := suite.T()
+	var paths []string
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		_ = json.NewEncoder(w).Encode(RecordsResponse{
+			Config: RecordsConfig{
+				DNS: DNS{
+					Hosts: []string{
+						"1.1.1.1 test-one.example.io",
+						"::ffff:10.0.0.1 test-two.example.io",
+						"fe80::1%eth0 test-three.example.io",
+						"b29f:3008:3ac4:753e:d124:f276:b92f:5d91 test-one.example.io",
+					},
+					CnameRecords: []string{"test-four.example.io,test-one.example.io"},
+				},
+				Misc: Misc{DnsmasqLines: []string{
+					dnsmasqBlockBegin,
+					"address=/apps.example.io/10.0.0.10",
+					"address=/apps.example.io/::ffff:10.0.0.10",
+					"txt-record=test-one.example.io,heritage=external-dns",
+					dnsmasqBlockEnd,
+				}},
+			},
+		})
+	})
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := p.Records(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"GET /api/config"}, paths)
+	assert.Equal(t, []*endpoint.Endpoint{
+		{DNSName: "test-one.example.io", Targets: endpoint.Targets{"1.1.1.1"}, RecordType: endpoint.RecordTypeA},
+		{DNSName: "*.apps.example.io", Targets: endpoint.Targets{"10.0.0.10"}, RecordType: endpoint.RecordTypeA},
+		{DNSName: "test-two.example.io", Targets: endpoint.Targets{"::ffff:10.0.0.1"}, RecordType: endpoint.RecordTypeAAAA},
+		{DNSName: "test-three.example.io", Targets: endpoint.Targets{"fe80::1%eth0"}, RecordType: endpoint.RecordTypeAAAA},
+		{DNSName: "test-one.example.io", Targets: endpoint.Targets{"b29f:3008:3ac4:753e:d124:f276:b92f:5d91"}, RecordType: endpoint.RecordTypeAAAA},
+		{DNSName: "*.apps.example.io", Targets: endpoint.Targets{"::ffff:10.0.0.10"}, RecordType: endpoint.RecordTypeAAAA},
+		{DNSName: "test-four.example.io", Targets: endpoint.Targets{"test-one.example.io"}, RecordType: endpoint.RecordTypeCNAME},
+		{DNSName: "test-one.example.io", Targets: endpoint.Targets{"heritage=external-dns"}, RecordType: endpoint.RecordTypeTXT},
+	}, records)
+}
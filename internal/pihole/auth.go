@@ -0,0 +1,132 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"net/http"
+)
+
+// Authentication modes selected with PIHOLE_AUTH_MODE.
+const (
+	authModePassword    = "password"
+	authModeAppPassword = "app-password"
+	authModeSID         = "sid"
+	authModeNone        = "none"
+)
+
+// Transports of the session ID selected with PIHOLE_SID_TRANSPORT.
+const (
+	sidTransportHeader = "header"
+	sidTransportCookie = "cookie"
+)
+
+// authenticator obtains the session that authenticates requests to the Pi-hole v6 API.
+type authenticator interface {
+	// login returns a session, or nil when requests are sent without one.
+	login(ctx context.Context, p *piholeClient) (*Session, error)
+	// ownsSession reports whether the sessions are created by the webhook, which then logs in
+	// again once a session is rejected and logs out on shutdown.
+	ownsSession() bool
+}
+
+// newAuthenticator returns the authenticator of the configured authentication mode.
+func newAuthenticator(cfg Config) (authenticator, error) {
+	switch cfg.SIDTransport {
+	case sidTransportHeader, sidTransportCookie, "":
+	default:
+		return nil, fmt.Errorf("unsupported session ID transport %q, expected %q or %q", cfg.SIDTransport, sidTransportHeader, sidTransportCookie)
+	}
+
+	switch cfg.AuthMode {
+	case authModePassword, "":
+		if cfg.Password == "" {
+			logger.Debugf("No password was supplied to External DNS")
+		}
+		return &passwordAuth{password: cfg.Password, totpSecret: cfg.TOTPSecret}, nil
+	case authModeAppPassword:
+		if cfg.AppPassword == "" {
+			return nil, errors.New("the app-password authentication mode requires PIHOLE_APP_PASSWORD")
+		}
+		// Application passwords bypass two-factor authentication.
+		return &passwordAuth{password: cfg.AppPassword}, nil
+	case authModeSID:
+		if cfg.SID == "" {
+			return nil, errors.New("the sid authentication mode requires PIHOLE_SID")
+		}
+		return &sessionAuth{sid: cfg.SID}, nil
+	case authModeNone:
+		return noAuth{}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedAuthMode, cfg.AuthMode)
+}
+
+// passwordAuth logs in with the admin password, along with a two-factor authentication code when
+// a TOTP secret is set, or with an application password.
+type passwordAuth struct {
+	password   string
+	totpSecret string
+}
+
+func (a *passwordAuth) login(ctx context.Context, p *piholeClient) (*Session, error) {
+	request := LoginRequest{Password: a.password}
+	var loginResponse LoginResponse
+	for attempt := 1; ; attempt++ {
+		if a.totpSecret != "" {
+			code, err := totpCode(a.totpSecret, p.now())
+			if err != nil {
+				return nil, err
+			}
+			request.TOTP = &code
+		}
+
+		_, err := p.callPiholeAttempt(ctx, http.MethodPost, "/auth", request, &loginResponse)
+		if err == nil {
+			return &loginResponse.Session, nil
+		}
+		if errors.Is(err, ErrTOTPRequired) && a.totpSecret == "" {
+			return nil, fmt.Errorf("%w, which requires PIHOLE_TOTP_SECRET to be set", err)
+		}
+		// A code is rejected when the clocks of the webhook and Pi-hole disagree, or when it was
+		// already used by a login within the same time step, so the code of the next time step
+		// is tried once.
+		if !errors.Is(err, ErrTOTPRejected) || a.totpSecret == "" || attempt > 1 {
+			return nil, err
+		}
+		wait := untilNextTOTPStep(p.now())
+		logger.Warningf("Pi-hole rejected the two-factor authentication code, retrying with the next one in %s", wait)
+		if err := p.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (a *passwordAuth) ownsSession() bool {
+	return true
+}
+
+// sessionAuth uses a session ID issued outside of the webhook, which can neither be renewed nor
+// ended by it.
+type sessionAuth struct {
+	sid string
+}
+
+func (a *sessionAuth) login(_ context.Context, _ *piholeClient) (*Session, error) {
+	return &Session{Valid: true, Sid: a.sid}, nil
+}
+
+func (a *sessionAuth) ownsSession() bool {
+	return false
+}
+
+// noAuth sends requests without a session, for Pi-holes without a password.
+type noAuth struct{}
+
+func (noAuth) login(_ context.Context, _ *piholeClient) (*Session, error) {
+	return nil, nil
+}
+
+func (noAuth) ownsSession() bool {
+	return false
+}
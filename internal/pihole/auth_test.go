@@ -0,0 +1,173 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// authModeServer records the logins and the requests made to the records API, answering the
+// latter with the given status.
+func (suite *PiholeTestSuite) authModeServer(status int, logins *[]LoginRequest, requests *[]*http.Request) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/info/version":
+			_ = json.NewEncoder(w).Encode(VersionResponse{})
+		case "/api/auth":
+			var request LoginRequest
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			*logins = append(*logins, request)
+			_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Sid: "login-sid", CSRF: "csrf", Valid: true}})
+		default:
+			*requests = append(*requests, r)
+			w.WriteHeader(status)
+			if status == http.StatusOK {
+				_ = json.NewEncoder(w).Encode(RecordsResponse{})
+			}
+		}
+	}
+}
+
+func (suite *PiholeTestSuite) TestAppPasswordAuth() {
+	t := suite.T()
+	var logins []LoginRequest
+	var requests []*http.Request
+	server := suite.newTestServer(suite.authModeServer(http.StatusOK, &logins, &requests))
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:      server.URL,
+		Password:    "admin",
+		TOTPSecret:  rfc6238Secret,
+		AuthMode:    authModeAppPassword,
+		AppPassword: "app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []LoginRequest{{Password: "app"}}, logins)
+	assert.Equal(t, "login-sid", requests[0].Header.Get("sid"))
+	assert.Empty(t, requests[0].Cookies())
+}
+
+func (suite *PiholeTestSuite) TestSIDAuth() {
+	t := suite.T()
+	var logins []LoginRequest
+	var requests []*http.Request
+	server := suite.newTestServer(suite.authModeServer(http.StatusOK, &logins, &requests))
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:   server.URL,
+		AuthMode: authModeSID,
+		SID:      "issued-sid",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Records(context.Background())
+
+	assert.Nil(t, err)
+	assert.Empty(t, logins)
+	assert.Equal(t, "issued-sid", requests[0].Header.Get("sid"))
+
+	// The session was issued outside of the webhook, so it is left open.
+	requests = nil
+	assert.Nil(t, p.Close(context.Background()))
+	assert.Empty(t, requests)
+}
+
+func (suite *PiholeTestSuite) TestSIDAuthRejected() {
+	t := suite.T()
+	var logins []LoginRequest
+	var requests []*http.Request
+	server := suite.newTestServer(suite.authModeServer(http.StatusUnauthorized, &logins, &requests))
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		AuthMode: authModeSID,
+		SID:      "issued-sid",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Empty(t, logins)
+	assert.Len(t, requests, 1)
+}
+
+func (suite *PiholeTestSuite) TestNoAuth() {
+	t := suite.T()
+	var logins []LoginRequest
+	var requests []*http.Request
+	server := suite.newTestServer(suite.authModeServer(http.StatusOK, &logins, &requests))
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		AuthMode: authModeNone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.Nil(t, err)
+	assert.Empty(t, logins)
+	assert.Empty(t, requests[0].Header.Get("sid"))
+	assert.Empty(t, requests[0].Cookies())
+}
+
+func (suite *PiholeTestSuite) TestSIDCookieTransport() {
+	t := suite.T()
+	var logins []LoginRequest
+	var requests []*http.Request
+	server := suite.newTestServer(suite.authModeServer(http.StatusOK, &logins, &requests))
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:       server.URL,
+		Password:     "password",
+		SIDTransport: sidTransportCookie,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.Nil(t, err)
+	assert.Empty(t, requests[0].Header.Get("sid"))
+	cookie, err := requests[0].Cookie("sid")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "login-sid", cookie.Value)
+	}
+	assert.Equal(t, "csrf", requests[0].Header.Get("X-FTL-CSRF"))
+}
+
+func (suite *PiholeTestSuite) TestInvalidAuthConfig() {
+	t := suite.T()
+	_, err := newAuthenticator(Config{AuthMode: "token"})
+	assert.ErrorIs(t, err, ErrUnsupportedAuthMode)
+
+	_, err = newAuthenticator(Config{AuthMode: authModeSID})
+	assert.ErrorContains(t, err, "PIHOLE_SID")
+
+	_, err = newAuthenticator(Config{AuthMode: authModeAppPassword})
+	assert.ErrorContains(t, err, "PIHOLE_APP_PASSWORD")
+
+	_, err = newAuthenticator(Config{SIDTransport: "query"})
+	assert.NotNil(t, err)
+}
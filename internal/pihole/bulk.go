@@ -0,0 +1,115 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+	"slices"
+)
+
+// bulkApi is implemented by clients able to apply a whole set of changes at once. Every write to
+// the Pi-hole configuration makes it rewrite its config files and reload dnsmasq, which takes a
+// while on small devices, so large syncs are much faster as a single write.
+type bulkApi interface {
+	// applyChanges deletes and creates the records of the given endpoints with a single
+	// configuration update.
+	applyChanges(ctx context.Context, deletes, creates []*endpoint.Endpoint) error
+}
+
+// bulkConfig holds the configuration arrays that records are kept in while a bulk apply is
+// staged, along with which of them were changed.
+type bulkConfig struct {
+	hosts          []string
+	cnameRecords   []string
+	dnsmasq        dnsmasqLines
+	dnsChanged     bool
+	dnsmasqChanged bool
+}
+
+// applyChanges stages every change against the current configuration and writes the resulting
+// arrays back with a single PATCH /config. Entries the webhook does not manage are kept as they are.
+func (p *piholeClient) applyChanges(ctx context.Context, deletes, creates []*endpoint.Endpoint) error {
+	return p.updateConfig(ctx, "/config", append(slices.Clip(deletes), creates...), func(current RecordsConfig) (*ConfigPatch, error) {
+		config := &bulkConfig{
+			hosts:        slices.Clone(current.DNS.Hosts),
+			cnameRecords: slices.Clone(current.DNS.CnameRecords),
+			dnsmasq:      splitDnsmasqLines(current.Misc.DnsmasqLines),
+		}
+		for _, ep := range deletes {
+			if err := p.stageRecord(config, http.MethodDelete, ep); err != nil {
+				return nil, err
+			}
+		}
+		for _, ep := range creates {
+			if err := p.stageRecord(config, http.MethodPut, ep); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.cfg.DryRun || (!config.dnsChanged && !config.dnsmasqChanged) {
+			return nil, nil
+		}
+
+		var patch ConfigPatch
+		if config.dnsChanged {
+			// Both arrays are always sent, as an empty array clears the setting while a missing
+			// one leaves it untouched.
+			patch.DNS = &DNS{
+				Hosts:        append([]string{}, config.hosts...),
+				CnameRecords: append([]string{}, config.cnameRecords...),
+			}
+		}
+		if config.dnsmasqChanged {
+			patch.Misc = &Misc{DnsmasqLines: config.dnsmasq.join()}
+		}
+
+		logger.Infof("Applying %d deletions and %d creations in a single configuration update", len(deletes), len(creates))
+		return &patch, nil
+	})
+}
+
+// stageRecord applies the creation or deletion of an endpoint to the staged configuration.
+func (p *piholeClient) stageRecord(config *bulkConfig, action string, ep *endpoint.Endpoint) error {
+	if !matchesDomainFilter(p.cfg.DomainFilter, ep) {
+		logger.Debugf("Skipping record %s that does not match domain filter", ep.DNSName)
+		return nil
+	}
+
+	for _, target := range ep.Targets {
+		if p.cfg.DryRun {
+			logger.Infof("DRY RUN: %s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, target)
+		} else {
+			logger.Infof("%s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, target)
+		}
+	}
+
+	create := action != http.MethodDelete
+	var changed bool
+	if isDnsmasqRecord(ep.RecordType, ep.DNSName) {
+		if !p.caps.DnsmasqLines {
+			return fmt.Errorf("record %s IN %s requires dnsmasq lines, which this Pi-hole does not support: %w", ep.DNSName, ep.RecordType, ErrUnsupportedRecordType)
+		}
+		lines := make([]string, 0, len(ep.Targets))
+		for _, target := range ep.Targets {
+			line, err := formatDnsmasqLine(ep.RecordType, ep.DNSName, target)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, line)
+		}
+		config.dnsmasq.managed, changed = applyEntries(config.dnsmasq.managed, lines, create, ep)
+		config.dnsmasqChanged = config.dnsmasqChanged || changed
+		return nil
+	}
+
+	var err error
+	if ep.RecordType == endpoint.RecordTypeCNAME {
+		config.cnameRecords, changed, err = applyHostEntries(config.cnameRecords, ep, create, p.caps.CnameTTL)
+	} else {
+		config.hosts, changed, err = applyHostEntries(config.hosts, ep, create, p.caps.CnameTTL)
+	}
+	config.dnsChanged = config.dnsChanged || changed
+	return err
+}
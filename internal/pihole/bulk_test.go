@@ -0,0 +1,249 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// configServer serves the whole configuration from config and records every PATCH /config request.
+func (suite *PiholeTestSuite) configServer(config *RecordsConfig, patches *[]ConfigPatch) *httptest.Server {
+	return suite.authedServer(suite.configHandler(config, patches))
+}
+
+// configHandler is the handler of configServer, without the version and login endpoints.
+func (suite *PiholeTestSuite) configHandler(config *RecordsConfig, patches *[]ConfigPatch) http.HandlerFunc {
+	t := suite.T()
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config":
+			_ = json.NewEncoder(w).Encode(RecordsResponse{Config: *config})
+		case r.Method == http.MethodPatch && r.URL.Path == "/api/config":
+			var request ConfigRequest
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+			*patches = append(*patches, request.Config)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}
+}
+
+func (suite *PiholeTestSuite) TestBulkApplyChanges() {
+	t := suite.T()
+	config := RecordsConfig{
+		DNS: DNS{
+			Hosts:        []string{"10.0.0.5 nas nas.lan", "1.1.1.1 test-one.example.io"},
+			CnameRecords: []string{"old.example.io,test-one.example.io"},
+		},
+		Misc: Misc{DnsmasqLines: []string{"local-ttl=60"}},
+	}
+	var patches []ConfigPatch
+	server := suite.configServer(&config, &patches)
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:    server.URL,
+		Password:  "password",
+		BulkApply: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-two.example.io", endpoint.RecordTypeA, "2.2.2.2", "3.3.3.3"),
+			endpoint.NewEndpoint("test-two.example.io", endpoint.RecordTypeTXT, "heritage=external-dns"),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("old.example.io", endpoint.RecordTypeCNAME, "test-one.example.io"),
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "4.4.4.4"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, patches, 1)
+	assert.Equal(t, &DNS{
+		Hosts:        []string{"10.0.0.5 nas nas.lan", "2.2.2.2 test-two.example.io", "3.3.3.3 test-two.example.io", "4.4.4.4 test-one.example.io"},
+		CnameRecords: []string{},
+	}, patches[0].DNS)
+	assert.Equal(t, &Misc{DnsmasqLines: []string{
+		"local-ttl=60",
+		dnsmasqBlockBegin,
+		"txt-record=test-two.example.io,heritage=external-dns",
+		dnsmasqBlockEnd,
+	}}, patches[0].Misc)
+}
+
+func (suite *PiholeTestSuite) TestBulkApplyMatchesParsedEntries() {
+	t := suite.T()
+	config := RecordsConfig{
+		DNS: DNS{
+			Hosts:        []string{"10.0.0.5\tnas nas.lan", "1.1.1.1  test-one.example.io # web"},
+			CnameRecords: []string{"a.example.io, b.example.io,test-one.example.io,300"},
+		},
+	}
+	var patches []ConfigPatch
+	server := suite.configServer(&config, &patches)
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:    server.URL,
+		Password:  "password",
+		BulkApply: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("nas.lan", endpoint.RecordTypeA, "10.0.0.5"),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("nas", endpoint.RecordTypeA, "10.0.0.5"),
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"),
+			endpoint.NewEndpoint("a.example.io", endpoint.RecordTypeCNAME, "test-one.example.io"),
+		},
+	})
+
+	assert.Nil(t, err)
+	if assert.Len(t, patches, 1) {
+		assert.Equal(t, &DNS{
+			Hosts:        []string{"10.0.0.5 nas.lan"},
+			CnameRecords: []string{"b.example.io,test-one.example.io,300"},
+		}, patches[0].DNS)
+	}
+}
+
+func (suite *PiholeTestSuite) TestBulkApplyOnlySendsChangedSettings() {
+	t := suite.T()
+	config := RecordsConfig{
+		DNS: DNS{Hosts: []string{"1.1.1.1 test-one.example.io"}},
+	}
+	var patches []ConfigPatch
+	server := suite.configServer(&config, &patches)
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:    server.URL,
+		Password:  "password",
+		BulkApply: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"),
+			endpoint.NewEndpoint("proxy.example.io", endpoint.RecordTypeCNAME, "test-one.example.io"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, patches, 1)
+	assert.Equal(t, []string{"1.1.1.1 test-one.example.io"}, patches[0].DNS.Hosts)
+	assert.Equal(t, []string{"proxy.example.io,test-one.example.io"}, patches[0].DNS.CnameRecords)
+	assert.Nil(t, patches[0].Misc)
+}
+
+func (suite *PiholeTestSuite) TestBulkApplyWithoutChanges() {
+	t := suite.T()
+	config := RecordsConfig{
+		DNS: DNS{Hosts: []string{"1.1.1.1 test-one.example.io"}},
+	}
+	var patches []ConfigPatch
+	server := suite.configServer(&config, &patches)
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:    server.URL,
+		Password:  "password",
+		BulkApply: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-two.example.io", endpoint.RecordTypeA, "2.2.2.2"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, patches)
+}
+
+func (suite *PiholeTestSuite) TestBulkApplyDryRun() {
+	t := suite.T()
+	config := RecordsConfig{}
+	var patches []ConfigPatch
+	server := suite.configServer(&config, &patches)
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:    server.URL,
+		Password:  "password",
+		BulkApply: true,
+		DryRun:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, patches)
+}
+
+func (suite *PiholeTestSuite) TestBulkApplyIncludesPTRs() {
+	t := suite.T()
+	config := RecordsConfig{}
+	var patches []ConfigPatch
+	server := suite.configServer(&config, &patches)
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:    server.URL,
+		Password:  "password",
+		BulkApply: true,
+		ManagePTR: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("app.lan", endpoint.RecordTypeA, "10.0.4.12"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, patches, 1)
+	assert.Equal(t, []string{"10.0.4.12 app.lan"}, patches[0].DNS.Hosts)
+	assert.Equal(t, []string{
+		dnsmasqBlockBegin,
+		"ptr-record=12.4.0.10.in-addr.arpa,app.lan",
+		dnsmasqBlockEnd,
+	}, patches[0].Misc.DnsmasqLines)
+}
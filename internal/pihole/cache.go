@@ -0,0 +1,77 @@
+package pihole
+
+import (
+	"sigs.k8s.io/external-dns/endpoint"
+	"sync"
+	"time"
+)
+
+// recordsCache keeps the records last listed from Pi-hole for a limited time, so that external-dns
+// polling an idle Pi-hole does not read its whole configuration every interval. A nil cache or one
+// with a zero TTL caches nothing.
+type recordsCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	now        func() time.Time
+	records    []*endpoint.Endpoint
+	expires    time.Time
+	generation uint64
+}
+
+func newRecordsCache(ttl time.Duration) *recordsCache {
+	return &recordsCache{ttl: ttl, now: time.Now}
+}
+
+// get returns a copy of the cached records along with the generation of the cache, which must be
+// passed to set when storing records listed after a miss.
+func (c *recordsCache) get() ([]*endpoint.Endpoint, uint64, bool) {
+	if c == nil || c.ttl <= 0 {
+		return nil, 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.records == nil || !c.now().Before(c.expires) {
+		recordsCacheRequestsTotal.WithLabelValues("miss").Inc()
+		return nil, c.generation, false
+	}
+	recordsCacheRequestsTotal.WithLabelValues("hit").Inc()
+	return copyEndpoints(c.records), c.generation, true
+}
+
+// set caches the records, unless the cache was invalidated since the given generation was read,
+// in which case the records may predate the changes that invalidated it.
+func (c *recordsCache) set(generation uint64, records []*endpoint.Endpoint) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.records = copyEndpoints(records)
+	c.expires = c.now().Add(c.ttl)
+}
+
+// invalidate drops the cached records.
+func (c *recordsCache) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = nil
+	c.generation++
+}
+
+// copyEndpoints deep copies endpoints, as external-dns modifies the records it is given.
+func copyEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	result := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		result = append(result, ep.DeepCopy())
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package pihole
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"time"
+)
+
+func (suite *PiholeTestSuite) TestRecordsServedFromCache() {
+	t := suite.T()
+	api := &fakeApi{records: map[string][]*endpoint.Endpoint{
+		endpoint.RecordTypeA: {endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1")},
+	}}
+	now := time.Now()
+	cache := newRecordsCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	p := &PiholeProvider{api: api, cache: cache}
+
+	first, err := p.Records(context.Background())
+	assert.Nil(t, err)
+	lists := api.lists
+
+	// external-dns adjusts the records it is given, which must not alter the cached ones.
+	first[0].RecordTTL = 300
+
+	second, err := p.Records(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, lists, api.lists)
+	assert.Equal(t, []*endpoint.Endpoint{endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1")}, second)
+
+	now = now.Add(time.Minute)
+	_, err = p.Records(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2*lists, api.lists)
+}
+
+func (suite *PiholeTestSuite) TestRecordsCacheInvalidatedByApplyChanges() {
+	t := suite.T()
+	api := &fakeApi{}
+	p := &PiholeProvider{api: api, cache: newRecordsCache(time.Minute)}
+
+	_, err := p.Records(context.Background())
+	assert.Nil(t, err)
+	lists := api.lists
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"),
+		},
+	})
+	assert.Nil(t, err)
+
+	_, err = p.Records(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2*lists, api.lists)
+}
+
+func (suite *PiholeTestSuite) TestRecordsCacheIgnoresStaleListing() {
+	t := suite.T()
+	cache := newRecordsCache(time.Minute)
+
+	_, generation, ok := cache.get()
+	assert.False(t, ok)
+
+	cache.invalidate()
+	cache.set(generation, []*endpoint.Endpoint{endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1")})
+
+	_, _, ok = cache.get()
+	assert.False(t, ok)
+}
+
+func (suite *PiholeTestSuite) TestRecordsCacheDisabled() {
+	t := suite.T()
+	api := &fakeApi{}
+	p := &PiholeProvider{api: api, cache: newRecordsCache(0)}
+
+	_, err := p.Records(context.Background())
+	assert.Nil(t, err)
+	lists := api.lists
+
+	_, err = p.Records(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2*lists, api.lists)
+}
@@ -3,55 +3,77 @@ package pihole
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/scaleway/scaleway-sdk-go/logger"
 	"net/http"
-	"net/http/cookiejar"
+	"net/netip"
 	"sigs.k8s.io/external-dns/endpoint"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
 // piholeAPI declares the "API" actions performed against the Pihole server.
 type piholeApi interface {
-	// listRecords returns endpoints for the given record type (A or CNAME).
-	listRecords(ctx context.Context, rtype string) ([]*endpoint.Endpoint, error)
-	// createRecord will create a new record for the given endpoint.
+	// listRecordsOfTypes returns the endpoints of all the given record types (A, AAAA, CNAME,
+	// TXT, SRV, MX, PTR or NS), in that order, grouping entries sharing a name into a single
+	// endpoint. Every setting records are kept in is read only once.
+	listRecordsOfTypes(ctx context.Context, rtypes ...string) ([]*endpoint.Endpoint, error)
+	// createRecord will create a new record for each target of the given endpoint.
 	createRecord(ctx context.Context, ep *endpoint.Endpoint) error
-	// deleteRecord will delete the given record.
+	// deleteRecord will delete the record of each target of the given endpoint.
 	deleteRecord(ctx context.Context, ep *endpoint.Endpoint) error
+	// capabilities returns the optional features supported by the Pi-hole server.
+	capabilities() Capabilities
+	// close releases the resources held on the Pi-hole server, such as the session.
+	close(ctx context.Context) error
 }
 
-// piholeClient implements the piholeAPI.
+// piholeClient implements the piholeAPI. It is safe for concurrent use: the session is guarded by
+// mu, and everything else is only set while the client is created.
 type piholeClient struct {
-	cfg        Config
-	httpClient *http.Client
-	session    *Session
+	cfg            Config
+	httpClient     *http.Client
+	auth           authenticator
+	mu             sync.Mutex
+	session        *Session
+	sessionExpires time.Time
+	login          *sessionLogin
+	now            func() time.Time
+	sleep          func(context.Context, time.Duration) error
+	version        ServerVersion
+	caps           Capabilities
+	listed         listedRecords
 }
 
-func (r *RecordsResponse) Records(rtype string) *[]Host {
+// Records parses the entries of the given record type, returning every host found along with a
+// ParseError for each entry that could not be parsed.
+func (r *RecordsResponse) Records(rtype string) ([]Host, []error) {
+	var entries []string
+	var parse func(string) ([]Host, error)
+	switch {
+	case strings.EqualFold(rtype, endpoint.RecordTypeCNAME):
+		entries, parse = r.Config.DNS.CnameRecords, parseCnameEntry
+	case strings.EqualFold(rtype, endpoint.RecordTypeA), strings.EqualFold(rtype, endpoint.RecordTypeAAAA):
+		entries, parse = r.Config.DNS.Hosts, parseHostsEntry
+	default:
+		return nil, nil
+	}
+
 	var result []Host
-	if strings.EqualFold(rtype, endpoint.RecordTypeCNAME) {
-		Map[string, Host](r.Config.DNS.CnameRecords, &result, func(s string) (Host, error) {
-			split := strings.Split(s, ",")
-			return Host{
-				name:   split[0],
-				target: split[1],
-			}, nil
-		})
-	}
-	if strings.EqualFold(rtype, endpoint.RecordTypeA) || strings.EqualFold(rtype, endpoint.RecordTypeAAAA) {
-		Map[string, Host](r.Config.DNS.Hosts, &result, func(s string) (Host, error) {
-			split := strings.Split(s, " ")
-			return Host{
-				name:   split[1],
-				target: split[0],
-			}, nil
-		})
-	}
-	return &result
+	var errs []error
+	for _, entry := range entries {
+		hosts, err := parse(entry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result = append(result, hosts...)
+	}
+	return result, errs
 }
 
 func pathForType(rtype string) (string, error) {
@@ -66,18 +88,146 @@ func pathForType(rtype string) (string, error) {
 	return "", errors.New("Unknown RecordType")
 }
 
-func pathForEndpoint(ep *endpoint.Endpoint) (string, error) {
-	switch ep.RecordType {
-	case endpoint.RecordTypeCNAME:
-		return fmt.Sprintf("/config/dns/cnameRecords/%s,%s", ep.DNSName, ep.Targets[0]), nil
-	case endpoint.RecordTypeA:
-		return fmt.Sprintf("/config/dns/hosts/%s %s", ep.Targets[0], ep.DNSName), nil
-	case endpoint.RecordTypeAAAA:
-		return fmt.Sprintf("/config/dns/hosts/%s %s", ep.Targets[0], ep.DNSName), nil
-	default:
-		return "", errors.New("Unknown RecordType")
+// entriesForEndpoint returns the Pi-hole entry of every target of the endpoint, as Pi-hole stores
+// every address of a name as a separate entry.
+func entriesForEndpoint(ep *endpoint.Endpoint, cnameTTL bool) ([]string, error) {
+	entries := make([]string, 0, len(ep.Targets))
+	for _, target := range ep.Targets {
+		switch ep.RecordType {
+		case endpoint.RecordTypeCNAME:
+			if cnameTTL && ep.RecordTTL.IsConfigured() {
+				entries = append(entries, fmt.Sprintf("%s,%s,%d", ep.DNSName, target, ep.RecordTTL))
+			} else {
+				entries = append(entries, fmt.Sprintf("%s,%s", ep.DNSName, target))
+			}
+		case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+			entries = append(entries, fmt.Sprintf("%s %s", target, ep.DNSName))
+		default:
+			return nil, errors.New("Unknown RecordType")
+		}
+	}
+	return entries, nil
+}
+
+// applyHostEntries adds the targets of an A, AAAA or CNAME endpoint to the dns.hosts or
+// dns.cnameRecords entries in current, or removes them, returning the resulting entries and whether
+// anything changed. Entries are matched on the names and targets they hold rather than on their
+// text, so entries separated by tabs, carrying comments or listing several names are recognized.
+// Removing a name from an entry that lists several rewrites the entry without that name only.
+func applyHostEntries(current []string, ep *endpoint.Endpoint, create, cnameTTL bool) ([]string, bool, error) {
+	entries, err := entriesForEndpoint(ep, cnameTTL)
+	if err != nil {
+		return nil, false, err
+	}
+	parse := parseHostsEntry
+	if ep.RecordType == endpoint.RecordTypeCNAME {
+		parse = parseCnameEntry
+	}
+
+	changed := false
+	for i, target := range ep.Targets {
+		holds := func(entry string) bool {
+			hosts, err := parse(entry)
+			return err == nil && slices.Contains(hostNames(hosts, target), ep.DNSName)
+		}
+		switch {
+		case create && !slices.ContainsFunc(current, holds):
+			current = append(current, entries[i])
+			changed = true
+		case !create && slices.ContainsFunc(current, holds):
+			var kept []string
+			for _, entry := range current {
+				if holds(entry) {
+					hosts, _ := parse(entry)
+					if entry = formatHostEntry(ep.RecordType, entry, hosts, ep.DNSName); entry == "" {
+						continue
+					}
+				}
+				kept = append(kept, entry)
+			}
+			current = kept
+			changed = true
+		default:
+			recordAlreadyApplied(create, ep, target)
+		}
+	}
+	return current, changed, nil
+}
+
+// hostNames returns the names of the hosts pointing at target.
+func hostNames(hosts []Host, target string) []string {
+	var names []string
+	for _, host := range hosts {
+		if host.target == target {
+			names = append(names, host.name)
+		}
+	}
+	return names
+}
+
+// formatHostEntry renders the hosts parsed from entry without the removed name, keeping the
+// comment of the entry. It returns an empty string when no name is left.
+func formatHostEntry(rtype, entry string, hosts []Host, removed string) string {
+	hosts = slices.DeleteFunc(slices.Clone(hosts), func(host Host) bool { return host.name == removed })
+	if len(hosts) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		names = append(names, host.name)
+	}
+	var formatted string
+	if rtype == endpoint.RecordTypeCNAME {
+		formatted = strings.Join(append(names, hosts[0].target), ",")
+		if hosts[0].ttl != 0 {
+			formatted += fmt.Sprintf(",%d", hosts[0].ttl)
+		}
+	} else {
+		formatted = hosts[0].target + " " + strings.Join(names, " ")
+	}
+	if i := strings.IndexByte(entry, '#'); i >= 0 {
+		formatted += " " + entry[i:]
+	}
+	return formatted
+}
+
+// pathsForEndpoint returns one API path per target of the endpoint.
+func pathsForEndpoint(ep *endpoint.Endpoint, cnameTTL bool) ([]string, error) {
+	entries, err := entriesForEndpoint(ep, cnameTTL)
+	if err != nil {
+		return nil, err
 	}
+	path, err := pathForType(ep.RecordType)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, path+"/"+entry)
+	}
+	return paths, nil
+}
 
+// validateConfig checks the parts of the configuration that connecting again cannot fix.
+func validateConfig(cfg Config) error {
+	if cfg.Server == "" {
+		return ErrNoPiholeServer
+	}
+	switch cfg.APIVersion {
+	case apiVersionAuto, apiVersion5, apiVersion6, "":
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAPIVersion, cfg.APIVersion)
+	}
+	if _, err := newTransport(cfg); err != nil {
+		return err
+	}
+	if cfg.APIVersion != apiVersion5 {
+		if _, err := newAuthenticator(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // newPiholeClient creates a new Pihole API client.
@@ -86,78 +236,123 @@ func newPiholeClient(cfg Config) (piholeApi, error) {
 		return nil, ErrNoPiholeServer
 	}
 
-	// Set up a persistent cookiejar for storing session information
-	jar, err := cookiejar.New(&cookiejar.Options{})
+	httpClient, err := newHTTPClient(cfg)
 	if err != nil {
 		return nil, err
 	}
+	apiVersion := cfg.APIVersion
+	if apiVersion == apiVersionAuto {
+		if apiVersion, err = probeAPIVersion(context.Background(), httpClient, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// An unset version keeps the v6 API the webhook was originally written for.
+	switch apiVersion {
+	case apiVersion5:
+		return newPiholeClientV5(cfg, httpClient)
+	case apiVersion6, "":
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAPIVersion, apiVersion)
+	}
 
-	// Set up an HTTP client using the cookiejar
-	httpClient := &http.Client{
-		Jar: jar,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
-			},
-		},
+	auth, err := newAuthenticator(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	p := &piholeClient{
 		cfg:        cfg,
 		httpClient: httpClient,
+		auth:       auth,
+		now:        time.Now,
+		sleep:      sleepContext,
+	}
+	if !p.restoreSession(context.Background()) {
+		if err := p.retrieveNewToken(context.Background()); err != nil {
+			return nil, err
+		}
 	}
-	if err := p.retrieveNewToken(context.Background()); err != nil {
+	if err := p.detectVersion(context.Background()); err != nil {
 		return nil, err
 	}
 
 	return p, nil
 }
 
-func (p *piholeClient) retrieveNewToken(ctx context.Context) error {
-	if p.cfg.Password == "" {
-		logger.Debugf("No password was supplied to External DNS")
+// detectVersion queries the component versions of the server and negotiates its capabilities.
+func (p *piholeClient) detectVersion(ctx context.Context) error {
+	var response VersionResponse
+	if _, err := p.callPihole(ctx, http.MethodGet, "/info/version", nil, &response); err != nil {
+		return err
+	}
+	p.version = ServerVersion{
+		Core: response.Version.Core.Local.Version,
+		Web:  response.Version.Web.Local.Version,
+		FTL:  response.Version.FTL.Local.Version,
 	}
 
-	var loginResponse LoginResponse
-	if _, err := p.callPihole(ctx, http.MethodPost, "/auth", LoginRequest{Password: p.cfg.Password}, &loginResponse); err != nil {
+	caps, err := capabilitiesFor(apiVersion6, p.version)
+	if err != nil {
 		return err
 	}
-	p.session = &loginResponse.Session
+	p.caps = caps
+	recordServerVersion(apiVersion6, p.version, p.caps)
 	return nil
 }
 
-func (p *piholeClient) listRecords(ctx context.Context, rtype string) ([]*endpoint.Endpoint, error) {
-	path, err := pathForType(rtype)
+func (p *piholeClient) capabilities() Capabilities {
+	return p.caps
+}
+
+// listRecordsOfTypes reads the whole configuration with a single request, and sorts the entries
+// of dns.hosts, dns.cnameRecords and the managed dnsmasq lines by record type in memory.
+func (p *piholeClient) listRecordsOfTypes(ctx context.Context, rtypes ...string) ([]*endpoint.Endpoint, error) {
+	config, err := p.readConfig(ctx, "/config")
 	if err != nil {
 		return nil, err
 	}
 
-	var response RecordsResponse
-	if _, err := p.callPihole(ctx, http.MethodGet, path, nil, &response); err != nil {
-		return nil, err
+	hosts := p.hostsByType(config, logParseErrors)
+	p.listed.set(hosts)
+	records := []*endpoint.Endpoint{}
+	for _, rtype := range rtypes {
+		records = append(records, endpointsForHosts(p.cfg.DomainFilter, rtype, hosts[rtype])...)
 	}
-	var endpoints []*endpoint.Endpoint
-	Map[Host, *endpoint.Endpoint](*response.Records(rtype), &endpoints, func(host Host) (*endpoint.Endpoint, error) {
-		if !p.cfg.DomainFilter.Match(host.name) {
-			logger.Debugf("Skipping record %s that does not match domain filter", host.name)
-			return nil, errors.New("Skipping record that does not match domain filter")
-		}
+	return records, nil
+}
 
-		if rtype == endpoint.RecordTypeA && strings.Contains(host.target, ":") {
-			return nil, errors.New("Skipping AAAA record type")
-		}
+// hostsByType parses the record settings of the configuration, grouping the hosts by record type.
+// The entries that could not be parsed are passed to onErrors, along with the kind of entry.
+func (p *piholeClient) hostsByType(config RecordsConfig, onErrors func(kind string, errs []error)) map[string][]Host {
+	byType := make(map[string][]Host)
+	response := RecordsResponse{Config: config}
+
+	hosts, parseErrs := response.Records(endpoint.RecordTypeA)
+	onErrors("hosts", parseErrs)
+	for _, host := range hosts {
+		rtype := addressRecordType(host.target)
+		byType[rtype] = append(byType[rtype], host)
+	}
 
-		if rtype == endpoint.RecordTypeAAAA && strings.Contains(host.target, ".") {
-			return nil, errors.New("Skipping A record type")
+	byType[endpoint.RecordTypeCNAME], parseErrs = response.Records(endpoint.RecordTypeCNAME)
+	onErrors(endpoint.RecordTypeCNAME, parseErrs)
+
+	if p.caps.DnsmasqLines {
+		parseErrs = nil
+		for _, line := range splitDnsmasqLines(config.Misc.DnsmasqLines).managed {
+			rtype, host, err := parseDnsmasqLine(line)
+			if err != nil {
+				parseErrs = append(parseErrs, err)
+				continue
+			}
+			if rtype != "" {
+				byType[rtype] = append(byType[rtype], host)
+			}
 		}
-
-		return &endpoint.Endpoint{
-			DNSName:    host.name,
-			Targets:    []string{host.target},
-			RecordType: rtype,
-		}, nil
-	})
-	return endpoints, nil
+		onErrors("dnsmasq", parseErrs)
+	}
+	return byType
 }
 
 func (p *piholeClient) createRecord(ctx context.Context, ep *endpoint.Endpoint) error {
@@ -169,44 +364,214 @@ func (p *piholeClient) deleteRecord(ctx context.Context, ep *endpoint.Endpoint)
 }
 
 func (p *piholeClient) manageRecord(ctx context.Context, action string, ep *endpoint.Endpoint) error {
-	if !p.cfg.DomainFilter.Match(ep.DNSName) {
+	if !matchesDomainFilter(p.cfg.DomainFilter, ep) {
 		logger.Debugf("Skipping record %s that does not match domain filter", ep.DNSName)
 		return nil
 	}
 
-	if p.cfg.DryRun {
-		logger.Infof("DRY RUN: %s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, ep.Targets[0])
-		return nil
+	if isDnsmasqRecord(ep.RecordType, ep.DNSName) {
+		if !p.caps.DnsmasqLines {
+			return fmt.Errorf("record %s IN %s requires dnsmasq lines, which this Pi-hole does not support: %w", ep.DNSName, ep.RecordType, ErrUnsupportedRecordType)
+		}
+		return p.manageDnsmasqRecord(ctx, action, ep)
 	}
 
-	logger.Infof("%s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, ep.Targets[0])
-
-	path, err := pathForEndpoint(ep)
+	paths, err := pathsForEndpoint(ep, p.caps.CnameTTL)
 	if err != nil {
 		return err
 	}
 
-	if _, err = p.callPihole(ctx, action, path, nil, nil); err != nil {
-		return err
+	for i, path := range paths {
+		if p.cfg.DryRun {
+			logger.Infof("DRY RUN: %s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, ep.Targets[i])
+			continue
+		}
+
+		logger.Infof("%s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, ep.Targets[i])
+
+		_, err = p.callPihole(ctx, action, path, nil, nil)
+		if action == http.MethodDelete && errors.Is(err, ErrRecordNotFound) {
+			// Pi-hole only deletes entries whose text matches exactly, so entries written
+			// differently, for example listing several names, are edited in the configuration.
+			err = p.deleteHostEntry(ctx, withTargets(ep, endpoint.Targets{ep.Targets[i]}))
+		}
+		if err != nil {
+			if err = ignoreAlreadyApplied(err, action == http.MethodPut, ep, ep.Targets[i]); err != nil {
+				return err
+			}
+		}
+		p.listed.apply(piholeEntryKey{ep.DNSName, ep.RecordType}, ep.Targets[i], action == http.MethodPut)
 	}
 
 	return nil
 }
 
+// deleteHostEntry removes the target of an A, AAAA or CNAME endpoint from the dns.hosts or
+// dns.cnameRecords entries holding it, whatever their text.
+func (p *piholeClient) deleteHostEntry(ctx context.Context, ep *endpoint.Endpoint) error {
+	return p.updateConfig(ctx, "/config", []*endpoint.Endpoint{ep}, func(current RecordsConfig) (*ConfigPatch, error) {
+		dns := DNS{Hosts: slices.Clone(current.DNS.Hosts), CnameRecords: slices.Clone(current.DNS.CnameRecords)}
+		var changed bool
+		var err error
+		if ep.RecordType == endpoint.RecordTypeCNAME {
+			dns.CnameRecords, changed, err = applyHostEntries(dns.CnameRecords, ep, false, p.caps.CnameTTL)
+		} else {
+			dns.Hosts, changed, err = applyHostEntries(dns.Hosts, ep, false, p.caps.CnameTTL)
+		}
+		if err != nil || !changed {
+			return nil, err
+		}
+		// Both arrays are always sent, as an empty array clears the setting while a missing one
+		// leaves it untouched.
+		dns.Hosts = append([]string{}, dns.Hosts...)
+		dns.CnameRecords = append([]string{}, dns.CnameRecords...)
+		return &ConfigPatch{DNS: &dns}, nil
+	})
+}
+
+// endpointsForHosts converts hosts of the given record type into endpoints, skipping hosts outside
+// the domain filter. Pi-hole keeps one entry per address, so entries sharing a name are grouped
+// into a single endpoint to present external-dns with a stable multi-target record.
+func endpointsForHosts(filter endpoint.DomainFilter, rtype string, hosts []Host) []*endpoint.Endpoint {
+	endpoints := []*endpoint.Endpoint{}
+	byName := make(map[string]*endpoint.Endpoint)
+	for _, host := range hosts {
+		if !filter.Match(domainFilterName(rtype, host.name, host.target)) {
+			logger.Debugf("Skipping record %s that does not match domain filter", host.name)
+			continue
+		}
+
+		if isAddressRecordType(rtype) && addressRecordType(host.target) != rtype {
+			continue
+		}
+
+		if ep, ok := byName[host.name]; ok {
+			if !slices.Contains(ep.Targets, host.target) {
+				ep.Targets = append(ep.Targets, host.target)
+			}
+			continue
+		}
+
+		ep := &endpoint.Endpoint{
+			DNSName:    host.name,
+			Targets:    []string{host.target},
+			RecordType: rtype,
+			RecordTTL:  host.ttl,
+		}
+		byName[host.name] = ep
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints
+}
+
+// addressRecordType returns the record type serving an address: A for IPv4 addresses and AAAA for
+// IPv6 ones, including IPv4-mapped IPv6 addresses and addresses with a zone. It returns an empty
+// string for anything that is not an address.
+func addressRecordType(address string) string {
+	addr, err := netip.ParseAddr(address)
+	switch {
+	case err != nil:
+		return ""
+	case addr.Is4():
+		return endpoint.RecordTypeA
+	default:
+		return endpoint.RecordTypeAAAA
+	}
+}
+
+// matchesDomainFilter reports whether every target of an endpoint falls within the domain filter.
+func matchesDomainFilter(filter endpoint.DomainFilter, ep *endpoint.Endpoint) bool {
+	for _, target := range ep.Targets {
+		if !filter.Match(domainFilterName(ep.RecordType, ep.DNSName, target)) {
+			return false
+		}
+	}
+	return true
+}
+
+// domainFilterName returns the name checked against the domain filter. PTR records live under
+// in-addr.arpa or ip6.arpa, so they are filtered by the name they point to instead.
+func domainFilterName(rtype, name, target string) string {
+	if rtype == endpoint.RecordTypePTR {
+		return target
+	}
+	return name
+}
+
+// ignoreAlreadyApplied treats creating a record that already exists and deleting one that is
+// already gone as success, so that an interrupted ApplyChanges can safely be run again.
+func ignoreAlreadyApplied(err error, create bool, ep *endpoint.Endpoint, target string) error {
+	if (create && errors.Is(err, ErrRecordExists)) || (!create && errors.Is(err, ErrRecordNotFound)) {
+		recordAlreadyApplied(create, ep, target)
+		return nil
+	}
+	return err
+}
+
+// recordAlreadyApplied logs and counts a change that Pi-hole already reflects.
+func recordAlreadyApplied(create bool, ep *endpoint.Endpoint, target string) {
+	if create {
+		logger.Infof("Record %s IN %s -> %s already exists, skipping", ep.DNSName, ep.RecordType, target)
+		alreadyAppliedTotal.WithLabelValues("create").Inc()
+	} else {
+		logger.Infof("Record %s IN %s -> %s is already gone, skipping", ep.DNSName, ep.RecordType, target)
+		alreadyAppliedTotal.WithLabelValues("delete").Inc()
+	}
+}
+
+// logParseErrors logs every entry that could not be parsed and counts them in metrics.
+func logParseErrors(kind string, errs []error) {
+	for _, err := range errs {
+		logger.Warningf("Skipping %s entry: %v", kind, err)
+	}
+	if len(errs) > 0 {
+		invalidEntriesTotal.WithLabelValues(kind).Add(float64(len(errs)))
+		logger.Warningf("Skipped %d invalid %s entries", len(errs), kind)
+	}
+}
+
+// callPihole calls the Pi-hole API, retrying according to the configured retry policy.
 func (p *piholeClient) callPihole(ctx context.Context, method string, path string, body interface{}, response interface{}) (*http.Response, error) {
+	var res *http.Response
+	err := p.cfg.Retry.run(ctx, func() error {
+		var err error
+		res, err = p.callPiholeAttempt(ctx, method, path, body, response)
+		return err
+	})
+	return res, err
+}
+
+// callPiholeAttempt makes a single attempt of a call, logging in first when the session is about
+// to expire. Logins are part of the attempt they are made in rather than being retried on their
+// own, so every request they send counts against the retry budget of the call.
+func (p *piholeClient) callPiholeAttempt(ctx context.Context, method string, path string, body interface{}, response interface{}) (*http.Response, error) {
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
-	url := fmt.Sprintf("%s/api%s", p.cfg.Server, path)
+
+	if path != "/auth" {
+		if err := p.ensureSession(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.callPiholeOnce(ctx, method, path, body != nil, jsonBody, response)
+}
+
+// callPiholeOnce makes a single attempt of a call, returning a retryableError for failures the
+// retry policy may recover from.
+func (p *piholeClient) callPiholeOnce(ctx context.Context, method string, path string, hasBody bool, jsonBody []byte, response interface{}) (*http.Response, error) {
+	url := apiURL(p.cfg, path)
 
 	logger.Debugf("Calling pihole %s %s", method, url)
 
 	var req *http.Request
-	if body == nil {
+	var err error
+	if !hasBody {
 		req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	} else {
-		req, err = http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonBody))
+		req, err = http.NewRequestWithContext(ctx, method, url, bytes.NewReader(jsonBody))
 	}
 	if err != nil {
 		return nil, err
@@ -214,26 +579,37 @@ func (p *piholeClient) callPihole(ctx context.Context, method string, path strin
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	if p.session != nil {
-		req.Header.Set("sid", p.session.Sid)
-	}
+	session := p.currentSession()
+	p.setSession(req, session)
 
 	res, err := p.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &retryableError{err: err, reason: "error", delay: useBackoff}
 	}
 
 	defer res.Body.Close()
 
-	if path != "/auth" && res.StatusCode == http.StatusUnauthorized {
-		if err := p.retrieveNewToken(ctx); err != nil {
+	if path != "/auth" && res.StatusCode == http.StatusUnauthorized && p.auth.ownsSession() {
+		reloginsTotal.Inc()
+		if err := p.renewSession(ctx, session); err != nil {
 			return nil, err
 		}
-		return p.callPihole(ctx, method, path, body, response)
+		return nil, &retryableError{err: errors.New("pihole session was rejected"), reason: "unauthorized"}
+	}
+
+	if p.cfg.Retry.retryable(res.StatusCode) {
+		return nil, &retryableError{err: newAPIError(res), reason: "status", delay: retryAfter(res)}
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusNoContent {
+		return nil, newAPIError(res)
 	}
 
-	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("received non-200 status code from request: %s", res.Status)
+	if path != "/auth" {
+		p.touchSession()
 	}
 
 	if response != nil {
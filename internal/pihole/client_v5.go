@@ -0,0 +1,284 @@
+package pihole
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"io"
+	"net/http"
+	"net/url"
+	"sigs.k8s.io/external-dns/endpoint"
+	"slices"
+)
+
+const (
+	apiVersion5 = "5"
+	apiVersion6 = "6"
+)
+
+// piholeClientV5 implements the piholeApi against the legacy Pi-hole v5 admin/api.php, which
+// authenticates every request with an API token instead of a session.
+type piholeClientV5 struct {
+	cfg        Config
+	httpClient *http.Client
+	token      string
+	version    ServerVersion
+}
+
+// newPiholeClientV5 creates a new Pihole v5 API client and checks that its token is accepted.
+func newPiholeClientV5(cfg Config, httpClient *http.Client) (piholeApi, error) {
+	p := &piholeClientV5{
+		cfg:        cfg,
+		httpClient: httpClient,
+		token:      v5Token(cfg),
+	}
+	if _, err := p.listHosts(context.Background(), endpoint.RecordTypeA); err != nil {
+		return nil, err
+	}
+	if err := p.detectVersion(context.Background()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// detectVersion queries the component versions of the server and checks they are supported.
+func (p *piholeClientV5) detectVersion(ctx context.Context) error {
+	var response V5VersionsResponse
+	if err := p.callPihole(ctx, "versions", nil, &response); err != nil {
+		return err
+	}
+	p.version = ServerVersion{
+		Core: response.Core,
+		Web:  response.Web,
+		FTL:  response.FTL,
+	}
+
+	caps, err := capabilitiesFor(apiVersion5, p.version)
+	if err != nil {
+		return err
+	}
+	recordServerVersion(apiVersion5, p.version, caps)
+	return nil
+}
+
+// capabilities returns no optional features, as the v5 API only manages Local DNS and CNAME
+// records.
+func (p *piholeClientV5) capabilities() Capabilities {
+	return Capabilities{}
+}
+
+// close does nothing, as the v5 API authenticates every request with the token instead of a session.
+func (p *piholeClientV5) close(_ context.Context) error {
+	return nil
+}
+
+// v5Token returns the configured API token, or derives it from the password the same way
+// Pi-hole v5 stores WEBPASSWORD: a double SHA-256 hex digest.
+func v5Token(cfg Config) string {
+	if cfg.APIToken != "" || cfg.Password == "" {
+		return cfg.APIToken
+	}
+	first := sha256.Sum256([]byte(cfg.Password))
+	second := sha256.Sum256([]byte(hex.EncodeToString(first[:])))
+	return hex.EncodeToString(second[:])
+}
+
+// v5ListForType returns the admin/api.php list holding the given record type.
+func v5ListForType(rtype string) (string, error) {
+	switch rtype {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		return "customdns", nil
+	case endpoint.RecordTypeCNAME:
+		return "customcname", nil
+	}
+	return "", ErrUnsupportedRecordType
+}
+
+// listRecordsOfTypes reads each of the lists holding the given record types once, skipping the
+// record types the v5 API does not support.
+func (p *piholeClientV5) listRecordsOfTypes(ctx context.Context, rtypes ...string) ([]*endpoint.Endpoint, error) {
+	hostsByList := make(map[string][]Host)
+	records := []*endpoint.Endpoint{}
+	for _, rtype := range rtypes {
+		list, err := v5ListForType(rtype)
+		if err != nil {
+			logger.Debugf("Skipping %s records, which are not supported by the Pi-hole v5 API", rtype)
+			continue
+		}
+		hosts, ok := hostsByList[list]
+		if !ok {
+			if hosts, err = p.listHosts(ctx, rtype); err != nil {
+				return nil, err
+			}
+			hostsByList[list] = hosts
+		}
+		records = append(records, endpointsForHosts(p.cfg.DomainFilter, rtype, hosts)...)
+	}
+	return records, nil
+}
+
+func (p *piholeClientV5) listHosts(ctx context.Context, rtype string) ([]Host, error) {
+	list, err := v5ListForType(rtype)
+	if err != nil {
+		return nil, err
+	}
+
+	var response V5ListResponse
+	if err := p.callPihole(ctx, list, url.Values{"action": {"get"}}, &response); err != nil {
+		return nil, err
+	}
+
+	var hosts []Host
+	var parseErrs []error
+	for _, entry := range response.Data {
+		if len(entry) != 2 || entry[0] == "" || entry[1] == "" {
+			parseErrs = append(parseErrs, &ParseError{Entry: fmt.Sprint(entry), Reason: "expected [<domain>, <target>]"})
+			continue
+		}
+		hosts = append(hosts, Host{
+			name:   entry[0],
+			target: entry[1],
+		})
+	}
+	logParseErrors(list, parseErrs)
+	return hosts, nil
+}
+
+func (p *piholeClientV5) createRecord(ctx context.Context, ep *endpoint.Endpoint) error {
+	return p.manageRecord(ctx, "add", ep)
+}
+
+func (p *piholeClientV5) deleteRecord(ctx context.Context, ep *endpoint.Endpoint) error {
+	return p.manageRecord(ctx, "delete", ep)
+}
+
+func (p *piholeClientV5) manageRecord(ctx context.Context, action string, ep *endpoint.Endpoint) error {
+	if !matchesDomainFilter(p.cfg.DomainFilter, ep) {
+		logger.Debugf("Skipping record %s that does not match domain filter", ep.DNSName)
+		return nil
+	}
+
+	list, err := v5ListForType(ep.RecordType)
+	if err != nil || isWildcard(ep.DNSName) {
+		return fmt.Errorf("record %s IN %s is not supported by the Pi-hole v5 API: %w", ep.DNSName, ep.RecordType, ErrUnsupportedRecordType)
+	}
+
+	for _, target := range ep.Targets {
+		if p.cfg.DryRun {
+			logger.Infof("DRY RUN: %s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, target)
+			continue
+		}
+
+		logger.Infof("%s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, target)
+
+		params := url.Values{"action": {action}, "domain": {ep.DNSName}}
+		if list == "customcname" {
+			params.Set("target", target)
+		} else {
+			params.Set("ip", target)
+		}
+
+		var response V5ActionResponse
+		if err := p.callPihole(ctx, list, params, &response); err != nil {
+			return err
+		}
+		if !response.Success {
+			err := &APIError{StatusCode: http.StatusOK, Message: response.Message}
+			// The v5 API refuses a second address of the same family for a domain with the same
+			// message as an identical entry, so only an identical entry counts as already created.
+			if action == "add" && errors.Is(err, ErrRecordExists) {
+				if exists, listErr := p.hasHost(ctx, ep.RecordType, ep.DNSName, target); listErr != nil || !exists {
+					return fmt.Errorf("pihole rejected %s of %s IN %s -> %s: %w", action, ep.DNSName, ep.RecordType, target, err)
+				}
+			}
+			if err := ignoreAlreadyApplied(err, action == "add", ep, target); err != nil {
+				return fmt.Errorf("pihole rejected %s of %s IN %s -> %s: %w", action, ep.DNSName, ep.RecordType, target, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasHost reports whether the list of the record type holds an entry for the name and target.
+func (p *piholeClientV5) hasHost(ctx context.Context, rtype, name, target string) (bool, error) {
+	hosts, err := p.listHosts(ctx, rtype)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(hosts, Host{name: name, target: target}), nil
+}
+
+// callPihole calls the Pi-hole v5 API, retrying according to the configured retry policy.
+func (p *piholeClientV5) callPihole(ctx context.Context, list string, params url.Values, response interface{}) error {
+	return p.cfg.Retry.run(ctx, func() error {
+		return p.callPiholeOnce(ctx, list, params, response)
+	})
+}
+
+// callPiholeOnce makes a single attempt of a call, returning a retryableError for failures the
+// retry policy may recover from.
+func (p *piholeClientV5) callPiholeOnce(ctx context.Context, list string, params url.Values, response interface{}) error {
+	logger.Debugf("Calling pihole %s %s", list, params.Get("action"))
+
+	query := url.Values{list: {""}, "auth": {p.token}}
+	for key, values := range params {
+		query[key] = values
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/admin/api.php?%s", p.cfg.Server, query.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		err = redactToken(err)
+		if ctx.Err() != nil {
+			return err
+		}
+		return &retryableError{err: err, reason: "error", delay: useBackoff}
+	}
+
+	defer res.Body.Close()
+
+	if p.cfg.Retry.retryable(res.StatusCode) {
+		return &retryableError{err: &APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}, reason: "status", delay: retryAfter(res)}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	// Pi-hole v5 answers an empty array when the token is not accepted.
+	if bytes.Equal(bytes.TrimSpace(body), []byte("[]")) {
+		return ErrInvalidAPIToken
+	}
+	return json.Unmarshal(body, response)
+}
+
+// redactToken removes the API token, which is as good as the password, from the URL of a failed
+// request, as the error ends up in the logs.
+func redactToken(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return &url.Error{Op: urlErr.Op, URL: "", Err: urlErr.Err}
+	}
+	query := redacted.Query()
+	query.Del("auth")
+	redacted.RawQuery = query.Encode()
+	return &url.Error{Op: urlErr.Op, URL: redacted.String(), Err: urlErr.Err}
+}
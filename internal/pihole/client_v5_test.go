@@ -0,0 +1,225 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// v5Server serves the Pi-hole v5 admin/api.php, answering get actions with the given entries
+// and recording the queries of every other action.
+func (suite *PiholeTestSuite) v5Server(customDNS, customCname [][]string, actions *[]map[string]string) *httptest.Server {
+	t := suite.T()
+	return suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/admin/api.php", r.URL.Path)
+		query := r.URL.Query()
+		if query.Get("auth") != v5Token(Config{Password: "password"}) {
+			_, _ = w.Write([]byte("[]"))
+			return
+		}
+		if query.Has("versions") {
+			_ = json.NewEncoder(w).Encode(V5VersionsResponse{Core: "v5.18.2", Web: "v5.21", FTL: "v5.25.1"})
+			return
+		}
+		if query.Get("action") == "get" {
+			data := customDNS
+			if query.Has("customcname") {
+				data = customCname
+			}
+			_ = json.NewEncoder(w).Encode(V5ListResponse{Data: data})
+			return
+		}
+		action := map[string]string{}
+		for key := range query {
+			action[key] = query.Get(key)
+		}
+		*actions = append(*actions, action)
+		_ = json.NewEncoder(w).Encode(V5ActionResponse{Success: true})
+	})
+}
+
+func (suite *PiholeTestSuite) TestV5Token() {
+	t := suite.T()
+	assert.Equal(t, "token", v5Token(Config{Password: "password", APIToken: "token"}))
+	assert.Equal(t, "", v5Token(Config{}))
+	// Matches WEBPASSWORD as written by "pihole -a -p password".
+	assert.Equal(t, "113459eb7bb31bddee85ade5230d6ad5d8b2fb52879e00a84ff6ae1067a210d3", v5Token(Config{Password: "password"}))
+}
+
+func (suite *PiholeTestSuite) TestV5IncorrectPassword() {
+	t := suite.T()
+	server := suite.v5Server(nil, nil, nil)
+	defer server.Close()
+
+	_, err := newPiholeClient(Config{
+		Server:     server.URL,
+		Password:   "incorrect",
+		APIVersion: apiVersion5,
+	})
+
+	assert.ErrorIs(t, err, ErrInvalidAPIToken)
+}
+
+func (suite *PiholeTestSuite) TestV5ErrorsHideToken() {
+	t := suite.T()
+	server := suite.v5Server(nil, nil, nil)
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		Password:   "password",
+		APIVersion: apiVersion5,
+		Retry:      fastRetryPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeA)
+	assert.ErrorContains(t, err, "/admin/api.php?")
+	assert.NotContains(t, err.Error(), v5Token(Config{Password: "password"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.listRecordsOfTypes(ctx, endpoint.RecordTypeA)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotContains(t, err.Error(), v5Token(Config{Password: "password"}))
+}
+
+func (suite *PiholeTestSuite) TestV5ListEndpoints() {
+	t := suite.T()
+	server := suite.v5Server(
+		[][]string{
+			{"test-one.example.io", "1.1.1.1"},
+			{"test-one.example.io", "2.2.2.2"},
+			{"test-two.example.io", "b29f:3008:3ac4:753e:d124:f276:b92f:5d91"},
+			{"invalid"},
+		},
+		[][]string{
+			{"test-three.example.io", "test-one.example.io"},
+		},
+		nil,
+	)
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		Password:   "password",
+		APIVersion: apiVersion5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeA)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, endpoint.Targets{"1.1.1.1", "2.2.2.2"}, records[0].Targets)
+
+	records, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeAAAA)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "test-two.example.io", records[0].DNSName)
+
+	records, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, endpoint.Targets{"test-one.example.io"}, records[0].Targets)
+
+	records, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeTXT)
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+}
+
+func (suite *PiholeTestSuite) TestV5CreateAndDeleteRecords() {
+	t := suite.T()
+	var actions []map[string]string
+	server := suite.v5Server(nil, nil, &actions)
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		Password:   "password",
+		APIVersion: apiVersion5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, client.createRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1")))
+	assert.Nil(t, client.deleteRecord(context.Background(), endpoint.NewEndpoint("test-two.example.io", endpoint.RecordTypeCNAME, "test-one.example.io")))
+
+	assert.Len(t, actions, 2)
+	assert.Equal(t, "add", actions[0]["action"])
+	assert.Contains(t, actions[0], "customdns")
+	assert.Equal(t, "test-one.example.io", actions[0]["domain"])
+	assert.Equal(t, "1.1.1.1", actions[0]["ip"])
+	assert.Equal(t, "delete", actions[1]["action"])
+	assert.Contains(t, actions[1], "customcname")
+	assert.Equal(t, "test-one.example.io", actions[1]["target"])
+}
+
+func (suite *PiholeTestSuite) TestV5UnsupportedRecord() {
+	t := suite.T()
+	server := suite.v5Server(nil, nil, nil)
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		Password:   "password",
+		APIVersion: apiVersion5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.createRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeTXT, "text"))
+	assert.True(t, errors.Is(err, ErrUnsupportedRecordType))
+
+	err = client.createRecord(context.Background(), endpoint.NewEndpoint("*.apps.example.io", endpoint.RecordTypeA, "1.1.1.1"))
+	assert.True(t, errors.Is(err, ErrUnsupportedRecordType))
+}
+
+func (suite *PiholeTestSuite) TestUnsupportedAPIVersion() {
+	t := suite.T()
+
+	_, err := newPiholeClient(Config{
+		Server:     "http://pi.hole",
+		APIVersion: "4",
+	})
+
+	assert.ErrorIs(t, err, ErrUnsupportedAPIVersion)
+}
+
+func (suite *PiholeTestSuite) TestV5CreateExistingRecord() {
+	t := suite.T()
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Has("versions"):
+			_ = json.NewEncoder(w).Encode(V5VersionsResponse{Core: "v5.18.2"})
+		case query.Get("action") == "get":
+			_ = json.NewEncoder(w).Encode(V5ListResponse{Data: [][]string{{"test-one.example.io", "1.1.1.1"}}})
+		default:
+			_ = json.NewEncoder(w).Encode(V5ActionResponse{Message: "This domain already has a custom DNS entry for an IPv4"})
+		}
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		APIVersion: apiVersion5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, client.createRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1")))
+
+	err = client.createRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "2.2.2.2"))
+	assert.ErrorIs(t, err, ErrRecordExists)
+}
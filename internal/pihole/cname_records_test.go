@@ -11,7 +11,7 @@ import (
 func (suite *PiholeTestSuite) TestCnameRecordsEndpoint() {
 	t := suite.T()
 	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
-		assert.Equal(t, "/api/config/dns/cnameRecords", r.URL.Path)
+		assert.Equal(t, "/api/config", r.URL.Path)
 	})
 	defer server.Close()
 
@@ -20,7 +20,7 @@ func (suite *PiholeTestSuite) TestCnameRecordsEndpoint() {
 		Password: "password",
 	})
 
-	_, _ = client.listRecords(context.Background(), "CNAME")
+	_, _ = client.listRecordsOfTypes(context.Background(), "CNAME")
 }
 
 func (suite *PiholeTestSuite) TestListCnameEndpoints() {
@@ -54,7 +54,7 @@ func (suite *PiholeTestSuite) TestListCnameEndpoints() {
 		t.Fatal(err)
 	}
 
-	records, err := cl.listRecords(context.Background(), "CNAME")
+	records, err := cl.listRecordsOfTypes(context.Background(), "CNAME")
 
 	assert.Len(t, records, 2)
 	assert.Equal(t, records[0].DNSName, "test-one.example.io")
@@ -81,3 +81,55 @@ func (suite *PiholeTestSuite) TestCreateCnameRecord() {
 		RecordType: endpoint.RecordTypeCNAME,
 	})
 }
+
+func (suite *PiholeTestSuite) TestCreateCnameRecordWithTTL() {
+	t := suite.T()
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/config/dns/cnameRecords/test-one.example.io,proxy-one.example.io,300", r.URL.Path)
+	})
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	_ = client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"proxy-one.example.io"},
+		DNSName:    "test-one.example.io",
+		RecordType: endpoint.RecordTypeCNAME,
+		RecordTTL:  300,
+	})
+}
+
+func (suite *PiholeTestSuite) TestListCnameEndpointsWithTTL() {
+	t := suite.T()
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(RecordsResponse{
+			Config: RecordsConfig{
+				DNS: DNS{
+					CnameRecords: []string{
+						"test-one.example.io,test-one.proxy.io,300",
+						"test-two.example.io,test-two.proxy.io",
+					},
+				},
+			},
+		})
+	})
+	defer server.Close()
+
+	cl, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "correct",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := cl.listRecordsOfTypes(context.Background(), "CNAME")
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, endpoint.TTL(300), records[0].RecordTTL)
+	assert.False(t, records[1].RecordTTL.IsConfigured())
+}
@@ -0,0 +1,167 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+	"slices"
+	"sync"
+)
+
+// updateConfig performs a read-modify-write of settings Pi-hole only accepts as a whole, such as
+// misc.dnsmasq_lines. Pi-hole offers no way to make such a write conditional, so the records the
+// changes touch are checked against the records ExternalDNS planned them on, as they were last
+// listed. When one of them changed since, for example because an administrator edited it in the
+// web interface, ErrConfigConflict is returned instead of overwriting the edit, and the changes
+// are planned again on the next sync. Entries of other records are kept as they are.
+//
+// plan must not modify the settings it is given, and returns a nil patch when there is nothing to
+// write.
+func (p *piholeClient) updateConfig(ctx context.Context, path string, changes []*endpoint.Endpoint, plan func(RecordsConfig) (*ConfigPatch, error)) error {
+	current, err := p.readConfig(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	keys := changedKeys(changes)
+	if key, ok := p.listed.changed(p.hostsByType(current, ignoreParseErrors), keys); ok {
+		conflictsTotal.Inc()
+		return fmt.Errorf("%w: %s IN %s changed since the records were listed", ErrConfigConflict, key.Target, key.RecordType)
+	}
+
+	patch, err := plan(current)
+	if err != nil || patch == nil {
+		return err
+	}
+	if _, err = p.callPihole(ctx, http.MethodPatch, "/config", ConfigRequest{Config: *patch}, nil); err != nil {
+		return err
+	}
+	p.listed.update(p.hostsByType(patchedConfig(current, *patch), ignoreParseErrors), keys)
+	return nil
+}
+
+// readConfig returns the record settings found at the given configuration path.
+func (p *piholeClient) readConfig(ctx context.Context, path string) (RecordsConfig, error) {
+	var response RecordsResponse
+	if _, err := p.callPihole(ctx, http.MethodGet, path, nil, &response); err != nil {
+		return RecordsConfig{}, err
+	}
+	return response.Config, nil
+}
+
+// patchedConfig returns the record settings once the patch is written.
+func patchedConfig(config RecordsConfig, patch ConfigPatch) RecordsConfig {
+	if patch.DNS != nil {
+		config.DNS = *patch.DNS
+	}
+	if patch.Misc != nil {
+		config.Misc = *patch.Misc
+	}
+	return config
+}
+
+// ignoreParseErrors drops the entries that could not be parsed, which were already reported when
+// the records were listed.
+func ignoreParseErrors(string, []error) {}
+
+// changedKeys returns the records the endpoints change.
+func changedKeys(changes []*endpoint.Endpoint) []piholeEntryKey {
+	var keys []piholeEntryKey
+	for _, ep := range changes {
+		key := piholeEntryKey{ep.DNSName, ep.RecordType}
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// listedRecords remembers the targets of every record as the records were last listed, which is
+// what ExternalDNS plans its changes on, along with the changes written since.
+type listedRecords struct {
+	mu      sync.Mutex
+	targets map[piholeEntryKey][]string
+}
+
+// set remembers the records of a listing.
+func (l *listedRecords) set(hosts map[string][]Host) {
+	targets := make(map[piholeEntryKey][]string)
+	for rtype, hosts := range hosts {
+		for _, host := range hosts {
+			key := piholeEntryKey{host.name, rtype}
+			targets[key] = append(targets[key], host.target)
+		}
+	}
+	for key, keyTargets := range targets {
+		slices.Sort(keyTargets)
+		targets[key] = slices.Compact(keyTargets)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.targets = targets
+}
+
+// changed returns the first of the records whose targets in hosts differ from the listed ones.
+// Until the records are listed, there is nothing to compare with and no record counts as changed.
+func (l *listedRecords) changed(hosts map[string][]Host, keys []piholeEntryKey) (piholeEntryKey, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.targets == nil {
+		return piholeEntryKey{}, false
+	}
+	for _, key := range keys {
+		if !slices.Equal(l.targets[key], hostTargets(hosts, key)) {
+			return key, true
+		}
+	}
+	return piholeEntryKey{}, false
+}
+
+// update remembers the targets in hosts of records that were just written.
+func (l *listedRecords) update(hosts map[string][]Host, keys []piholeEntryKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.targets == nil {
+		return
+	}
+	for _, key := range keys {
+		l.store(key, hostTargets(hosts, key))
+	}
+}
+
+// apply remembers a target added to or removed from a record by writing a single entry.
+func (l *listedRecords) apply(key piholeEntryKey, target string, create bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.targets == nil {
+		return
+	}
+	targets := slices.DeleteFunc(slices.Clone(l.targets[key]), func(t string) bool { return t == target })
+	if create {
+		targets = append(targets, target)
+		slices.Sort(targets)
+	}
+	l.store(key, targets)
+}
+
+func (l *listedRecords) store(key piholeEntryKey, targets []string) {
+	if len(targets) == 0 {
+		delete(l.targets, key)
+		return
+	}
+	l.targets[key] = targets
+}
+
+// hostTargets returns the sorted targets of a record found in hosts.
+func hostTargets(hosts map[string][]Host, key piholeEntryKey) []string {
+	var targets []string
+	for _, host := range hosts[key.RecordType] {
+		if host.name == key.Target && !slices.Contains(targets, host.target) {
+			targets = append(targets, host.target)
+		}
+	}
+	slices.Sort(targets)
+	return targets
+}
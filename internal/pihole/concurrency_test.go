@@ -0,0 +1,163 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// editingServer serves the configuration from config, applying and recording every PATCH /config
+// request, so tests can change config between listing and applying records like an administrator
+// editing it in the web interface.
+func (suite *PiholeTestSuite) editingServer(config *RecordsConfig, patches *[]ConfigPatch) *httptest.Server {
+	t := suite.T()
+	return suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && (r.URL.Path == "/api/config" || r.URL.Path == "/api/config/misc/dnsmasq_lines"):
+			_ = json.NewEncoder(w).Encode(RecordsResponse{Config: *config})
+		case r.Method == http.MethodPatch && r.URL.Path == "/api/config":
+			var request ConfigRequest
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+			*patches = append(*patches, request.Config)
+			*config = patchedConfig(*config, request.Config)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+}
+
+// listRecords creates a provider for the server and lists its records, as ExternalDNS does before
+// planning changes.
+func (suite *PiholeTestSuite) listRecords(cfg Config) *PiholeProvider {
+	t := suite.T()
+	cfg.Password = "password"
+	p, err := NewPiholeProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Records(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func (suite *PiholeTestSuite) TestDnsmasqUpdateKeepsUnrelatedEdits() {
+	t := suite.T()
+	config := RecordsConfig{Misc: Misc{DnsmasqLines: []string{
+		dnsmasqBlockBegin,
+		"txt-record=test-one.example.io,plain",
+		dnsmasqBlockEnd,
+	}}}
+	var patches []ConfigPatch
+	server := suite.editingServer(&config, &patches)
+	defer server.Close()
+
+	p := suite.listRecords(Config{Server: server.URL})
+	config.Misc.DnsmasqLines = append([]string{"local-ttl=60"}, config.Misc.DnsmasqLines...)
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeTXT, "plain")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeTXT, "changed")},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, patches, 2)
+	assert.Equal(t, []string{
+		"local-ttl=60",
+		dnsmasqBlockBegin,
+		"txt-record=test-one.example.io,changed",
+		dnsmasqBlockEnd,
+	}, config.Misc.DnsmasqLines)
+}
+
+func (suite *PiholeTestSuite) TestDnsmasqUpdateFailsWhenRecordEdited() {
+	t := suite.T()
+	config := RecordsConfig{Misc: Misc{DnsmasqLines: []string{
+		dnsmasqBlockBegin,
+		"txt-record=test-one.example.io,plain",
+		dnsmasqBlockEnd,
+	}}}
+	var patches []ConfigPatch
+	server := suite.editingServer(&config, &patches)
+	defer server.Close()
+
+	p := suite.listRecords(Config{Server: server.URL})
+	config.Misc.DnsmasqLines[1] = "txt-record=test-one.example.io,edited"
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeTXT, "plain")},
+	})
+
+	assert.ErrorIs(t, err, ErrConfigConflict)
+	assert.Empty(t, patches)
+}
+
+func (suite *PiholeTestSuite) TestDnsmasqCreateFailsWhenRecordAdded() {
+	t := suite.T()
+	config := RecordsConfig{}
+	var patches []ConfigPatch
+	server := suite.editingServer(&config, &patches)
+	defer server.Close()
+
+	p := suite.listRecords(Config{Server: server.URL})
+	config.Misc.DnsmasqLines = []string{
+		dnsmasqBlockBegin,
+		"txt-record=test-one.example.io,edited",
+		dnsmasqBlockEnd,
+	}
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeTXT, "plain")},
+	})
+
+	assert.ErrorIs(t, err, ErrConfigConflict)
+	assert.Empty(t, patches)
+}
+
+func (suite *PiholeTestSuite) TestBulkApplyKeepsUnrelatedEdits() {
+	t := suite.T()
+	config := RecordsConfig{
+		DNS: DNS{Hosts: []string{"1.1.1.1 test-one.example.io"}},
+	}
+	var patches []ConfigPatch
+	server := suite.editingServer(&config, &patches)
+	defer server.Close()
+
+	p := suite.listRecords(Config{Server: server.URL, BulkApply: true})
+	config.DNS.Hosts = append(config.DNS.Hosts, "10.0.0.5 nas.lan")
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, patches, 1)
+	assert.Equal(t, []string{"10.0.0.5 nas.lan"}, patches[0].DNS.Hosts)
+}
+
+func (suite *PiholeTestSuite) TestBulkApplyFailsWhenRecordEdited() {
+	t := suite.T()
+	config := RecordsConfig{
+		DNS: DNS{Hosts: []string{"1.1.1.1 test-one.example.io"}},
+	}
+	var patches []ConfigPatch
+	server := suite.editingServer(&config, &patches)
+	defer server.Close()
+
+	p := suite.listRecords(Config{Server: server.URL, BulkApply: true})
+	config.DNS.Hosts = append(config.DNS.Hosts, "2.2.2.2 test-one.example.io")
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "4.4.4.4")},
+	})
+
+	assert.ErrorIs(t, err, ErrConfigConflict)
+	assert.Empty(t, patches)
+}
@@ -1,7 +1,19 @@
 package pihole
 
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+	Took  float64     `json:"took"`
+}
+
+type ErrorDetail struct {
+	Key     string  `json:"key"`
+	Message string  `json:"message"`
+	Hint    *string `json:"hint"`
+}
+
 type LoginRequest struct {
 	Password string `json:"password"`
+	TOTP     *int   `json:"totp,omitempty"`
 }
 
 type LoginResponse struct {
@@ -9,7 +21,55 @@ type LoginResponse struct {
 	Took    float64 `json:"took"`
 }
 
+type VersionResponse struct {
+	Version ComponentVersions `json:"version"`
+	Took    float64           `json:"took"`
+}
+
+type ComponentVersions struct {
+	Core ComponentVersion `json:"core"`
+	Web  ComponentVersion `json:"web"`
+	FTL  ComponentVersion `json:"ftl"`
+}
+
+type ComponentVersion struct {
+	Local LocalVersion `json:"local"`
+}
+
+type LocalVersion struct {
+	Version string `json:"version"`
+}
+
 type RecordsResponse struct {
 	Config RecordsConfig `json:"config"`
 	Took   float64       `json:"took"`
 }
+
+type ConfigRequest struct {
+	Config ConfigPatch `json:"config"`
+}
+
+// ConfigPatch holds the parts of the Pi-hole configuration replaced by a PATCH /config request,
+// leaving out the parts that are not set.
+type ConfigPatch struct {
+	DNS  *DNS  `json:"dns,omitempty"`
+	Misc *Misc `json:"misc,omitempty"`
+}
+
+// V5ListResponse is returned by the Pi-hole v5 customdns and customcname get actions.
+type V5ListResponse struct {
+	Data [][]string `json:"data"`
+}
+
+// V5ActionResponse is returned by the Pi-hole v5 customdns and customcname add and delete actions.
+type V5ActionResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// V5VersionsResponse is returned by the Pi-hole v5 versions query.
+type V5VersionsResponse struct {
+	Core string `json:"core_current"`
+	Web  string `json:"web_current"`
+	FTL  string `json:"FTL_current"`
+}
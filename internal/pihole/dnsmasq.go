@@ -0,0 +1,330 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"net/http"
+	"net/netip"
+	"sigs.k8s.io/external-dns/endpoint"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Pi-hole v6 appends misc.dnsmasq_lines verbatim to its dnsmasq configuration. Records Pi-hole
+// cannot express natively are kept there, inside a block delimited by the markers below, so lines
+// added by administrators are never touched.
+const (
+	dnsmasqBlockBegin = "# BEGIN external-dns-pihole-webhook managed records"
+	dnsmasqBlockEnd   = "# END external-dns-pihole-webhook managed records"
+)
+
+const dnsmasqLinesPath = "/config/misc/dnsmasq_lines"
+
+// dnsmasqOptions maps the record types stored as dnsmasq lines to their dnsmasq option.
+var dnsmasqOptions = map[string]string{
+	endpoint.RecordTypeTXT: "txt-record",
+	endpoint.RecordTypeSRV: "srv-host",
+	endpoint.RecordTypeMX:  "mx-host",
+	endpoint.RecordTypePTR: "ptr-record",
+	endpoint.RecordTypeNS:  "server",
+}
+
+// dnsmasqAddressOption answers for a domain and all of its subdomains, which is how wildcard A and
+// AAAA records are expressed since dns.hosts cannot hold them.
+const dnsmasqAddressOption = "address"
+
+func isDnsmasqRecordType(rtype string) bool {
+	_, ok := dnsmasqOptions[rtype]
+	return ok
+}
+
+// isDnsmasqRecord reports whether a record is stored as a dnsmasq line rather than as a native
+// Pi-hole entry.
+func isDnsmasqRecord(rtype, name string) bool {
+	return isDnsmasqRecordType(rtype) || (isAddressRecordType(rtype) && isWildcard(name))
+}
+
+func isAddressRecordType(rtype string) bool {
+	return rtype == endpoint.RecordTypeA || rtype == endpoint.RecordTypeAAAA
+}
+
+func isWildcard(name string) bool {
+	return strings.HasPrefix(name, "*.")
+}
+
+// dnsmasqLines holds misc.dnsmasq_lines split around the block managed by the webhook.
+type dnsmasqLines struct {
+	before  []string
+	managed []string
+	after   []string
+}
+
+func splitDnsmasqLines(lines []string) dnsmasqLines {
+	var result dnsmasqLines
+	inBlock, seenBlock := false, false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !seenBlock && trimmed == dnsmasqBlockBegin:
+			inBlock, seenBlock = true, true
+		case inBlock && trimmed == dnsmasqBlockEnd:
+			inBlock = false
+		case inBlock:
+			result.managed = append(result.managed, line)
+		case seenBlock:
+			result.after = append(result.after, line)
+		default:
+			result.before = append(result.before, line)
+		}
+	}
+	return result
+}
+
+// join reassembles the lines, dropping the managed block entirely once it is empty.
+func (d dnsmasqLines) join() []string {
+	lines := append([]string{}, d.before...)
+	if len(d.managed) > 0 {
+		lines = append(lines, dnsmasqBlockBegin)
+		lines = append(lines, d.managed...)
+		lines = append(lines, dnsmasqBlockEnd)
+	}
+	return append(lines, d.after...)
+}
+
+// formatDnsmasqLine renders a single target of a record as a dnsmasq option line.
+func formatDnsmasqLine(rtype, name, target string) (string, error) {
+	if strings.ContainsAny(name, ",\r\n") || strings.ContainsAny(target, "\r\n") {
+		return "", fmt.Errorf("record %s IN %s -> %s cannot be expressed as a dnsmasq line", name, rtype, target)
+	}
+
+	switch rtype {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		domain := strings.TrimPrefix(name, "*.")
+		if !isWildcard(name) || strings.ContainsAny(domain, "/*") || strings.Contains(target, "/") {
+			return "", fmt.Errorf("record %s IN %s -> %s is not a wildcard record", name, rtype, target)
+		}
+		return fmt.Sprintf("%s=/%s/%s", dnsmasqAddressOption, domain, target), nil
+	case endpoint.RecordTypeTXT:
+		text, err := quoteTxt(target)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s=%s,%s", dnsmasqOptions[rtype], name, text), nil
+	case endpoint.RecordTypeSRV:
+		// external-dns writes SRV targets as "<priority> <weight> <port> <target>", dnsmasq
+		// expects "<name>,<target>,<port>,<priority>,<weight>".
+		fields := strings.Fields(target)
+		if len(fields) != 4 || !isUint16(fields[0]) || !isUint16(fields[1]) || !isUint16(fields[2]) {
+			return "", fmt.Errorf("invalid SRV target %q, expected \"<priority> <weight> <port> <target>\"", target)
+		}
+		return fmt.Sprintf("%s=%s,%s,%s,%s,%s", dnsmasqOptions[rtype], name, fields[3], fields[2], fields[0], fields[1]), nil
+	case endpoint.RecordTypePTR:
+		return fmt.Sprintf("%s=%s,%s", dnsmasqOptions[rtype], name, target), nil
+	case endpoint.RecordTypeNS:
+		// NS records delegate the zone by forwarding its queries to the target servers.
+		if strings.Contains(name, "/") || !isForwardTarget(target) {
+			return "", fmt.Errorf("invalid NS target %q, expected \"<address>[#<port>]\"", target)
+		}
+		return fmt.Sprintf("%s=/%s/%s", dnsmasqOptions[rtype], name, target), nil
+	case endpoint.RecordTypeMX:
+		// external-dns writes MX targets as "<preference> <host>", dnsmasq expects
+		// "<name>,<host>,<preference>".
+		fields := strings.Fields(target)
+		if len(fields) != 2 || !isUint16(fields[0]) {
+			return "", fmt.Errorf("invalid MX target %q, expected \"<preference> <host>\"", target)
+		}
+		return fmt.Sprintf("%s=%s,%s,%s", dnsmasqOptions[rtype], name, fields[1], fields[0]), nil
+	default:
+		return "", errors.New("Unknown RecordType")
+	}
+}
+
+// parseDnsmasqLine parses a line of the managed block back into its record type and host.
+// Blank and comment lines yield an empty record type and no error.
+func parseDnsmasqLine(line string) (string, Host, error) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", Host{}, nil
+	}
+
+	option, value, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", Host{}, &ParseError{Entry: line, Reason: "missing option value"}
+	}
+
+	switch option {
+	case dnsmasqAddressOption:
+		fields := strings.Split(value, "/")
+		if len(fields) != 3 || fields[0] != "" || fields[1] == "" {
+			return "", Host{}, &ParseError{Entry: line, Reason: "expected /<domain>/<address>"}
+		}
+		rtype := addressRecordType(fields[2])
+		if rtype == "" {
+			return "", Host{}, &ParseError{Entry: line, Reason: "invalid address " + strconv.Quote(fields[2])}
+		}
+		return rtype, Host{name: "*." + fields[1], target: fields[2]}, nil
+	case dnsmasqOptions[endpoint.RecordTypeNS]:
+		fields := strings.Split(value, "/")
+		if len(fields) != 3 || fields[0] != "" || fields[1] == "" || !isForwardTarget(fields[2]) {
+			return "", Host{}, &ParseError{Entry: line, Reason: "expected /<zone>/<address>[#<port>]"}
+		}
+		return endpoint.RecordTypeNS, Host{name: fields[1], target: fields[2]}, nil
+	case dnsmasqOptions[endpoint.RecordTypeTXT]:
+		name, text, ok := strings.Cut(value, ",")
+		if !ok || name == "" || text == "" {
+			return "", Host{}, &ParseError{Entry: line, Reason: "missing TXT name or text"}
+		}
+		if text, ok = unquoteTxt(text); !ok {
+			return "", Host{}, &ParseError{Entry: line, Reason: "unterminated quote in TXT text"}
+		}
+		return endpoint.RecordTypeTXT, Host{name: name, target: text}, nil
+	case dnsmasqOptions[endpoint.RecordTypeSRV]:
+		fields := strings.Split(value, ",")
+		if len(fields) != 5 || fields[0] == "" || fields[1] == "" || !isUint16(fields[2]) || !isUint16(fields[3]) || !isUint16(fields[4]) {
+			return "", Host{}, &ParseError{Entry: line, Reason: "expected <name>,<target>,<port>,<priority>,<weight>"}
+		}
+		return endpoint.RecordTypeSRV, Host{
+			name:   fields[0],
+			target: strings.Join([]string{fields[3], fields[4], fields[2], fields[1]}, " "),
+		}, nil
+	case dnsmasqOptions[endpoint.RecordTypeMX]:
+		fields := strings.Split(value, ",")
+		if len(fields) != 3 || fields[0] == "" || fields[1] == "" || !isUint16(fields[2]) {
+			return "", Host{}, &ParseError{Entry: line, Reason: "expected <name>,<host>,<preference>"}
+		}
+		return endpoint.RecordTypeMX, Host{
+			name:   fields[0],
+			target: fields[2] + " " + fields[1],
+		}, nil
+	case dnsmasqOptions[endpoint.RecordTypePTR]:
+		name, target, ok := strings.Cut(value, ",")
+		if !ok || name == "" || target == "" || strings.Contains(target, ",") {
+			return "", Host{}, &ParseError{Entry: line, Reason: "expected <name>,<target>"}
+		}
+		return endpoint.RecordTypePTR, Host{name: name, target: target}, nil
+	default:
+		return "", Host{}, &ParseError{Entry: line, Reason: "unsupported option " + option}
+	}
+}
+
+func isUint16(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 16)
+	return err == nil
+}
+
+// isForwardTarget reports whether target is an upstream server as accepted by dnsmasq's server
+// option, an address optionally followed by "#<port>".
+func isForwardTarget(target string) bool {
+	address, port, hasPort := strings.Cut(target, "#")
+	if _, err := netip.ParseAddr(address); err != nil {
+		return false
+	}
+	return !hasPort || isUint16(port)
+}
+
+// txtSpecialChars are the characters dnsmasq would split, trim or unescape in unquoted TXT text.
+const txtSpecialChars = ",\"\\ \t#"
+
+// quoteTxt quotes TXT text that dnsmasq would otherwise split or trim. Text that is already
+// quoted, such as the external-dns TXT registry records, is written as is. Other text is quoted
+// only from its first to its last special character, which dnsmasq reads the same way, so that
+// unquoteTxt can tell the two apart and read back exactly the text that was written.
+func quoteTxt(text string) (string, error) {
+	if isQuotedTxt(text) {
+		return text, nil
+	}
+	first, last := strings.IndexAny(text, txtSpecialChars), strings.LastIndexAny(text, txtSpecialChars)
+	if first < 0 {
+		return text, nil
+	}
+	if first == 0 && last == len(text)-1 {
+		return "", fmt.Errorf("TXT text %q starts and ends with characters dnsmasq cannot store unquoted", text)
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text[first : last+1])
+	return text[:first] + `"` + escaped + `"` + text[last+1:], nil
+}
+
+// unquoteTxt reverses quoteTxt: fully quoted text is returned as is, and the quoted parts of other
+// text are unquoted the way dnsmasq reads them.
+func unquoteTxt(text string) (string, bool) {
+	if isQuotedTxt(text) {
+		return text, true
+	}
+
+	var result strings.Builder
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '"':
+			quoted = !quoted
+		case quoted && c == '\\' && i+1 < len(text) && strings.IndexByte(`"\`, text[i+1]) >= 0:
+			i++
+			result.WriteByte(text[i])
+		default:
+			result.WriteByte(c)
+		}
+	}
+	return result.String(), !quoted
+}
+
+func isQuotedTxt(text string) bool {
+	return len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`)
+}
+
+// manageDnsmasqRecord adds or removes the lines of an endpoint in the managed block, rewriting
+// misc.dnsmasq_lines in a single request unless it was changed concurrently.
+func (p *piholeClient) manageDnsmasqRecord(ctx context.Context, action string, ep *endpoint.Endpoint) error {
+	var lines []string
+	for _, target := range ep.Targets {
+		line, err := formatDnsmasqLine(ep.RecordType, ep.DNSName, target)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+
+		if p.cfg.DryRun {
+			logger.Infof("DRY RUN: %s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, target)
+		} else {
+			logger.Infof("%s %s IN %s -> %s", action, ep.DNSName, ep.RecordType, target)
+		}
+	}
+	if p.cfg.DryRun || len(lines) == 0 {
+		return nil
+	}
+
+	return p.updateConfig(ctx, dnsmasqLinesPath, []*endpoint.Endpoint{ep}, func(config RecordsConfig) (*ConfigPatch, error) {
+		current := splitDnsmasqLines(config.Misc.DnsmasqLines)
+		var changed bool
+		current.managed, changed = applyEntries(current.managed, lines, action != http.MethodDelete, ep)
+		// Leave the configuration alone when there is nothing to change, as every write makes
+		// Pi-hole reload dnsmasq.
+		if !changed {
+			return nil, nil
+		}
+		return &ConfigPatch{Misc: &Misc{DnsmasqLines: current.join()}}, nil
+	})
+}
+
+// applyEntries adds the entries of an endpoint to current, or removes them from it, returning the
+// resulting entries and whether anything changed. Entries that are already in the requested state
+// are counted as already applied.
+func applyEntries(current, entries []string, create bool, ep *endpoint.Endpoint) ([]string, bool) {
+	changed := false
+	for i, entry := range entries {
+		matches := func(existing string) bool { return strings.TrimSpace(existing) == entry }
+		switch {
+		case !create && slices.ContainsFunc(current, matches):
+			current = slices.DeleteFunc(current, matches)
+			changed = true
+		case create && !slices.ContainsFunc(current, matches):
+			current = append(current, entry)
+			changed = true
+		default:
+			recordAlreadyApplied(create, ep, ep.Targets[i])
+		}
+	}
+	return current, changed
+}
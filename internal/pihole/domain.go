@@ -1,10 +1,15 @@
 package pihole
 
-import "sigs.k8s.io/external-dns/endpoint"
+import (
+	"sigs.k8s.io/external-dns/endpoint"
+	"time"
+)
 
 type Session struct {
-	Valid bool   `json:"valid"`
-	Sid   string `json:"sid"`
+	Valid    bool   `json:"valid"`
+	Sid      string `json:"sid"`
+	CSRF     string `json:"csrf"`
+	Validity int    `json:"validity"`
 }
 
 type DNS struct {
@@ -12,20 +17,52 @@ type DNS struct {
 	CnameRecords []string `json:"cnameRecords"`
 }
 
+type Misc struct {
+	DnsmasqLines []string `json:"dnsmasq_lines"`
+}
+
 type RecordsConfig struct {
-	DNS DNS `json:"dns"`
+	DNS  DNS  `json:"dns"`
+	Misc Misc `json:"misc"`
+}
+
+// ServerVersion holds the component versions reported by the Pi-hole server.
+type ServerVersion struct {
+	Core string
+	Web  string
+	FTL  string
 }
 
 type Host struct {
 	name   string
 	target string
+	ttl    endpoint.TTL
 }
 
 type Config struct {
-	Server                string `env:"PIHOLE_SERVER" envDefault:"http://pi.hole:80"`
-	Password              string `env:"PIHOLE_PASSWORD" envDefault:""`
-	TLSInsecureSkipVerify bool   `env:"PIHOLE_TLS_INSECURE" envDefault:"false"`
-	DryRun                bool   `env:"PIHOLE_DRY_RUN" envDefault:"false"`
+	Server                string        `env:"PIHOLE_SERVER" envDefault:"http://pi.hole:80"`
+	Password              string        `env:"PIHOLE_PASSWORD" envDefault:""`
+	TOTPSecret            string        `env:"PIHOLE_TOTP_SECRET" envDefault:""`
+	AuthMode              string        `env:"PIHOLE_AUTH_MODE" envDefault:"password"`
+	AppPassword           string        `env:"PIHOLE_APP_PASSWORD" envDefault:""`
+	SID                   string        `env:"PIHOLE_SID" envDefault:""`
+	SIDTransport          string        `env:"PIHOLE_SID_TRANSPORT" envDefault:"header"`
+	SessionFile           string        `env:"PIHOLE_SESSION_FILE" envDefault:""`
+	APIVersion            string        `env:"PIHOLE_API_VERSION" envDefault:"auto"`
+	APIToken              string        `env:"PIHOLE_API_TOKEN" envDefault:""`
+	APIPath               string        `env:"PIHOLE_API_PATH" envDefault:"/api"`
+	TLSInsecureSkipVerify bool          `env:"PIHOLE_TLS_INSECURE" envDefault:"false"`
+	TLSCAFile             string        `env:"PIHOLE_TLS_CA_FILE" envDefault:""`
+	TLSCertFile           string        `env:"PIHOLE_TLS_CERT_FILE" envDefault:""`
+	TLSKeyFile            string        `env:"PIHOLE_TLS_KEY_FILE" envDefault:""`
+	TLSServerName         string        `env:"PIHOLE_TLS_SERVER_NAME" envDefault:""`
+	TLSMinVersion         string        `env:"PIHOLE_TLS_MIN_VERSION" envDefault:"1.2"`
+	DryRun                bool          `env:"PIHOLE_DRY_RUN" envDefault:"false"`
+	ManagePTR             bool          `env:"PIHOLE_MANAGE_PTR" envDefault:"false"`
+	BulkApply             bool          `env:"PIHOLE_BULK_APPLY" envDefault:"false"`
+	CacheTTL              time.Duration `env:"PIHOLE_CACHE_TTL" envDefault:"0s"`
+	Retry                 RetryPolicy
+	Transport             TransportConfig
 	DomainFilter          endpoint.DomainFilter
 }
 
@@ -1,5 +1,97 @@
 package pihole
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
 
-var ErrNoPiholeServer = errors.New("no pihole server found in the environment or flags")
+var (
+	ErrNoPiholeServer           = errors.New("no pihole server found in the environment or flags")
+	ErrNotReady                 = errors.New("pihole provider is not connected yet")
+	ErrUnsupportedAPIVersion    = errors.New("unsupported pihole API version")
+	ErrUnsupportedServerVersion = errors.New("unsupported pihole version")
+	ErrUnsupportedAuthMode      = errors.New("unsupported pihole authentication mode")
+	ErrUnsupportedRecordType    = errors.New("unsupported record type")
+	ErrUnauthorized             = errors.New("pihole rejected the credentials")
+	ErrInvalidAPIToken          = fmt.Errorf("%w: the API token was not accepted", ErrUnauthorized)
+	ErrTOTPRequired             = errors.New("pihole requires a two-factor authentication code")
+	ErrTOTPRejected             = errors.New("pihole rejected the two-factor authentication code")
+	ErrRecordExists             = errors.New("record already exists")
+	ErrRecordNotFound           = errors.New("record not found")
+	ErrConfigReadOnly           = errors.New("pihole configuration is read-only")
+	ErrConfigConflict           = errors.New("pihole configuration was changed concurrently")
+	ErrInvalidTLSConfig         = errors.New("invalid pihole TLS configuration")
+	ErrInvalidTransportConfig   = errors.New("invalid pihole HTTP transport configuration")
+)
+
+// ParseError is returned for a Pi-hole entry that does not follow the expected format.
+type ParseError struct {
+	Entry  string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid entry %q: %s", e.Entry, e.Reason)
+}
+
+// APIError is an error reported by the Pi-hole API, decoded from its error body. It matches the
+// sentinel errors above with errors.Is according to its status, key and message.
+type APIError struct {
+	StatusCode int
+	Key        string
+	Message    string
+	Hint       string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("pihole API error %d", e.StatusCode)
+	if e.Key != "" {
+		msg += " " + e.Key
+	}
+	msg += ": " + e.Message
+	if e.Hint != "" {
+		msg += " (" + e.Hint + ")"
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool {
+	message := strings.ToLower(e.Message)
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.Key == "unauthorized"
+	case ErrRecordExists:
+		return strings.Contains(message, "already")
+	case ErrRecordNotFound:
+		return strings.Contains(message, "item not found") || strings.Contains(message, "does not exist")
+	case ErrTOTPRequired:
+		return strings.Contains(message, "no 2fa token")
+	case ErrTOTPRejected:
+		return strings.Contains(message, "invalid 2fa token") || strings.Contains(message, "reused 2fa token")
+	case ErrConfigReadOnly:
+		return e.Key == "forbidden" || strings.Contains(message, "read-only") || strings.Contains(message, "environment")
+	}
+	return false
+}
+
+// newAPIError decodes the error body of a failed Pi-hole v6 response, falling back to the status
+// text when the body holds no error.
+func newAPIError(res *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+
+	var response ErrorResponse
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if err != nil || json.Unmarshal(body, &response) != nil || response.Error.Message == "" {
+		return apiErr
+	}
+	apiErr.Key = response.Error.Key
+	apiErr.Message = response.Error.Message
+	if response.Error.Hint != nil {
+		apiErr.Hint = *response.Error.Hint
+	}
+	return apiErr
+}
@@ -0,0 +1,65 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func (suite *PiholeTestSuite) TestAPIErrorFromBody() {
+	t := suite.T()
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"key":"bad_request","message":"Config items set via environment variables cannot be changed via the API","hint":"dns.hosts"},"took":0.003}`))
+	})
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"))
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, &APIError{
+		StatusCode: http.StatusBadRequest,
+		Key:        "bad_request",
+		Message:    "Config items set via environment variables cannot be changed via the API",
+		Hint:       "dns.hosts",
+	}, apiErr)
+	assert.ErrorIs(t, err, ErrConfigReadOnly)
+	assert.Equal(t, "pihole API error 400 bad_request: Config items set via environment variables cannot be changed via the API (dns.hosts)", err.Error())
+}
+
+func (suite *PiholeTestSuite) TestAPIErrorWithoutBody() {
+	t := suite.T()
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	defer server.Close()
+
+	_, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "incorrect",
+	})
+
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, "pihole API error 401: Unauthorized", err.Error())
+}
+
+func (suite *PiholeTestSuite) TestAPIErrorSentinels() {
+	t := suite.T()
+
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusBadRequest, Key: "bad_request", Message: "Item already present"}, ErrRecordExists)
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusNotFound, Key: "not_found", Message: "Item not found"}, ErrRecordNotFound)
+	assert.NotErrorIs(t, &APIError{StatusCode: http.StatusNotFound, Key: "not_found", Message: "Not found"}, ErrRecordNotFound)
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusForbidden, Key: "forbidden", Message: "Config items set via environment variables cannot be changed via the API"}, ErrConfigReadOnly)
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusOK, Message: "This domain already has a custom DNS entry for an IPv4"}, ErrRecordExists)
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusOK, Message: "This domain/ip association does not exist"}, ErrRecordNotFound)
+	assert.ErrorIs(t, ErrInvalidAPIToken, ErrUnauthorized)
+	assert.NotErrorIs(t, &APIError{StatusCode: http.StatusBadRequest, Key: "bad_request", Message: "Invalid request"}, ErrRecordExists)
+}
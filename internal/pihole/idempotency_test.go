@@ -0,0 +1,141 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func (suite *PiholeTestSuite) TestCreateExistingRecord() {
+	t := suite.T()
+	var paths []string
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/api/config/dns/hosts/1.1.1.1 test-one.example.io" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"key":"bad_request","message":"Item already present","hint":"Uniqueness of items is enforced"}}`))
+		}
+	})
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2"))
+
+	assert.Nil(t, err)
+	assert.Len(t, paths, 2)
+}
+
+func (suite *PiholeTestSuite) TestDeleteMissingRecord() {
+	t := suite.T()
+	var patches int
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config":
+			_ = json.NewEncoder(w).Encode(RecordsResponse{
+				Config: RecordsConfig{DNS: DNS{CnameRecords: []string{"other.example.io,proxy.example.io"}}},
+			})
+		case r.Method == http.MethodPatch:
+			patches++
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"key":"not_found","message":"Item not found","hint":null}}`))
+		}
+	})
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.deleteRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeCNAME, "proxy.example.io"))
+
+	assert.Nil(t, err)
+	assert.Zero(t, patches)
+}
+
+func (suite *PiholeTestSuite) TestDeleteRecordFromEntryWithSeveralNames() {
+	t := suite.T()
+	config := RecordsConfig{DNS: DNS{
+		Hosts:        []string{"10.0.0.5\tnas nas.lan # storage", "1.1.1.1 test-one.example.io"},
+		CnameRecords: []string{"proxy.example.io,test-one.example.io"},
+	}}
+	var patches []ConfigPatch
+	configHandler := suite.configHandler(&config, &patches)
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"key":"not_found","message":"Item not found","hint":null}}`))
+			return
+		}
+		configHandler(w, r)
+	})
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.deleteRecord(context.Background(), endpoint.NewEndpoint("nas", endpoint.RecordTypeA, "10.0.0.5"))
+
+	assert.Nil(t, err)
+	if assert.Len(t, patches, 1) {
+		assert.Equal(t, &DNS{
+			Hosts:        []string{"10.0.0.5 nas.lan # storage", "1.1.1.1 test-one.example.io"},
+			CnameRecords: []string{"proxy.example.io,test-one.example.io"},
+		}, patches[0].DNS)
+	}
+}
+
+func (suite *PiholeTestSuite) TestCreateRecordFailsOnOtherErrors() {
+	t := suite.T()
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"key":"bad_request","message":"Invalid request","hint":null}}`))
+	})
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1"))
+
+	assert.NotNil(t, err)
+}
+
+func (suite *PiholeTestSuite) TestDnsmasqChangesAlreadyApplied() {
+	t := suite.T()
+	lines := []string{
+		dnsmasqBlockBegin,
+		"txt-record=test-one.example.io,plain",
+		dnsmasqBlockEnd,
+	}
+	patches := 0
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			patches++
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	assert.Nil(t, client.createRecord(context.Background(), endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeTXT, "plain")))
+	assert.Nil(t, client.deleteRecord(context.Background(), endpoint.NewEndpoint("test-two.example.io", endpoint.RecordTypeTXT, "plain")))
+	assert.Equal(t, 0, patches)
+}
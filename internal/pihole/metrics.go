@@ -0,0 +1,68 @@
+package pihole
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "pihole_webhook"
+
+var invalidEntriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Name:      "invalid_entries_total",
+	Help:      "Number of Pi-hole entries skipped because they could not be parsed.",
+}, []string{"kind"})
+
+var serverInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: metricsNamespace,
+	Name:      "server_info",
+	Help:      "Versions of the Pi-hole server the webhook is connected to.",
+}, []string{"api_version", "core", "web", "ftl"})
+
+var retriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Name:      "retries_total",
+	Help:      "Number of calls to the Pi-hole server retried, by reason.",
+}, []string{"reason"})
+
+var reloginsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Name:      "relogins_total",
+	Help:      "Number of times the webhook logged in again after its session was rejected.",
+})
+
+var alreadyAppliedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Name:      "already_applied_changes_total",
+	Help:      "Number of record changes skipped because Pi-hole already reflected them, by action.",
+}, []string{"action"})
+
+var conflictsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Name:      "config_conflicts_total",
+	Help:      "Number of configuration updates refused because the records they change were edited since they were listed.",
+})
+
+var recordsCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Name:      "records_cache_requests_total",
+	Help:      "Number of record listings served from the records cache or from Pi-hole, by result.",
+}, []string{"result"})
+
+var sessionValid = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: metricsNamespace,
+	Name:      "session_valid",
+	Help:      "Whether the webhook holds a valid Pi-hole session.",
+})
+
+var sessionExpiry = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: metricsNamespace,
+	Name:      "session_expiry_timestamp_seconds",
+	Help:      "Time at which the Pi-hole session expires unless it is used again.",
+})
+
+var sessionRefreshesTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Name:      "session_refreshes_total",
+	Help:      "Number of times the webhook logged in again because its session was about to expire.",
+})
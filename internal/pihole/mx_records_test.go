@@ -0,0 +1,59 @@
+package pihole
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func (suite *PiholeTestSuite) TestListMxEndpoints() {
+	t := suite.T()
+	lines := []string{
+		dnsmasqBlockBegin,
+		"mx-host=example.io,mail.example.io,10",
+		"mx-host=example.io,backup.example.io,20",
+		dnsmasqBlockEnd,
+	}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeMX)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "example.io", records[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"10 mail.example.io", "20 backup.example.io"}, records[0].Targets)
+}
+
+func (suite *PiholeTestSuite) TestCreateMxRecord() {
+	t := suite.T()
+	lines := []string{}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"10 mail.example.io"},
+		DNSName:    "example.io",
+		RecordType: endpoint.RecordTypeMX,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		dnsmasqBlockBegin,
+		"mx-host=example.io,mail.example.io,10",
+		dnsmasqBlockEnd,
+	}, lines)
+}
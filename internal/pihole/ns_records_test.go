@@ -0,0 +1,82 @@
+package pihole
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func (suite *PiholeTestSuite) TestListNsEndpoints() {
+	t := suite.T()
+	lines := []string{
+		"server=/corp.example.lan/192.168.1.1",
+		dnsmasqBlockBegin,
+		"server=/svc.cluster.lan/10.43.0.10",
+		"server=/svc.cluster.lan/10.43.0.11#5353",
+		dnsmasqBlockEnd,
+	}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeNS)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "svc.cluster.lan", records[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"10.43.0.10", "10.43.0.11#5353"}, records[0].Targets)
+}
+
+func (suite *PiholeTestSuite) TestCreateNsRecord() {
+	t := suite.T()
+	lines := []string{}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"10.43.0.10", "fd00::10#5353"},
+		DNSName:    "svc.cluster.lan",
+		RecordType: endpoint.RecordTypeNS,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		dnsmasqBlockBegin,
+		"server=/svc.cluster.lan/10.43.0.10",
+		"server=/svc.cluster.lan/fd00::10#5353",
+		dnsmasqBlockEnd,
+	}, lines)
+}
+
+func (suite *PiholeTestSuite) TestCreateNsRecordRequiresAddress() {
+	t := suite.T()
+	lines := []string{}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"ns1.example.lan"},
+		DNSName:    "svc.cluster.lan",
+		RecordType: endpoint.RecordTypeNS,
+	})
+
+	assert.NotNil(t, err)
+	assert.Empty(t, lines)
+}
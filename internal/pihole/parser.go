@@ -0,0 +1,80 @@
+package pihole
+
+import (
+	"net/netip"
+	"sigs.k8s.io/external-dns/endpoint"
+	"strconv"
+	"strings"
+)
+
+// stripComment removes a trailing "# comment" from a Pi-hole entry.
+func stripComment(entry string) string {
+	if i := strings.IndexByte(entry, '#'); i >= 0 {
+		return entry[:i]
+	}
+	return entry
+}
+
+// parseHostsEntry parses a dns.hosts entry of the form "<address> <name> [<alias>...] [# comment]".
+// Fields may be separated by any amount of whitespace, and every name on the line is returned as
+// its own Host. Blank and comment-only entries yield no hosts and no error.
+func parseHostsEntry(entry string) ([]Host, error) {
+	fields := strings.Fields(stripComment(entry))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if len(fields) < 2 {
+		return nil, &ParseError{Entry: entry, Reason: "missing host name"}
+	}
+	if _, err := netip.ParseAddr(fields[0]); err != nil {
+		return nil, &ParseError{Entry: entry, Reason: "invalid address " + strconv.Quote(fields[0])}
+	}
+
+	hosts := make([]Host, 0, len(fields)-1)
+	for _, name := range fields[1:] {
+		hosts = append(hosts, Host{
+			name:   name,
+			target: fields[0],
+		})
+	}
+	return hosts, nil
+}
+
+// parseCnameEntry parses a dns.cnameRecords entry of the form "<name>[,<alias>...],<target>[,<ttl>]",
+// following the dnsmasq cname syntax. Every name on the entry is returned as its own Host.
+func parseCnameEntry(entry string) ([]Host, error) {
+	trimmed := strings.TrimSpace(stripComment(entry))
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(trimmed, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+		if fields[i] == "" {
+			return nil, &ParseError{Entry: entry, Reason: "empty field"}
+		}
+	}
+
+	var ttl endpoint.TTL
+	if len(fields) > 2 {
+		if value, err := strconv.ParseUint(fields[len(fields)-1], 10, 32); err == nil {
+			ttl = endpoint.TTL(value)
+			fields = fields[:len(fields)-1]
+		}
+	}
+	if len(fields) < 2 {
+		return nil, &ParseError{Entry: entry, Reason: "missing target"}
+	}
+
+	target := fields[len(fields)-1]
+	hosts := make([]Host, 0, len(fields)-1)
+	for _, name := range fields[:len(fields)-1] {
+		hosts = append(hosts, Host{
+			name:   name,
+			target: target,
+			ttl:    ttl,
+		})
+	}
+	return hosts, nil
+}
@@ -0,0 +1,103 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func (suite *PiholeTestSuite) TestParseHostsEntry() {
+	t := suite.T()
+	cases := []struct {
+		entry string
+		hosts []Host
+	}{
+		{"1.1.1.1 test-one.example.io", []Host{{name: "test-one.example.io", target: "1.1.1.1"}}},
+		{"1.1.1.1\ttest-one.example.io ", []Host{{name: "test-one.example.io", target: "1.1.1.1"}}},
+		{"10.0.0.5  nas   nas.lan", []Host{{name: "nas", target: "10.0.0.5"}, {name: "nas.lan", target: "10.0.0.5"}}},
+		{"10.0.0.5 nas # storage", []Host{{name: "nas", target: "10.0.0.5"}}},
+		{"fe80::1%eth0 router.lan", []Host{{name: "router.lan", target: "fe80::1%eth0"}}},
+		{"# only a comment", nil},
+		{"", nil},
+	}
+
+	for _, c := range cases {
+		hosts, err := parseHostsEntry(c.entry)
+		assert.Nil(t, err, c.entry)
+		assert.Equal(t, c.hosts, hosts, c.entry)
+	}
+}
+
+func (suite *PiholeTestSuite) TestParseInvalidHostsEntry() {
+	t := suite.T()
+	for _, entry := range []string{"1.1.1.1", "not-an-address test-one.example.io", "1.1.1.1# test-one.example.io"} {
+		hosts, err := parseHostsEntry(entry)
+		var parseErr *ParseError
+		assert.ErrorAs(t, err, &parseErr, entry)
+		assert.Equal(t, entry, parseErr.Entry)
+		assert.Nil(t, hosts, entry)
+	}
+}
+
+func (suite *PiholeTestSuite) TestParseCnameEntry() {
+	t := suite.T()
+	cases := []struct {
+		entry string
+		hosts []Host
+	}{
+		{"test-one.example.io,proxy.example.io", []Host{{name: "test-one.example.io", target: "proxy.example.io"}}},
+		{"test-one.example.io, proxy.example.io,300", []Host{{name: "test-one.example.io", target: "proxy.example.io", ttl: 300}}},
+		{"a.example.io,b.example.io,proxy.example.io", []Host{{name: "a.example.io", target: "proxy.example.io"}, {name: "b.example.io", target: "proxy.example.io"}}},
+		{" ", nil},
+	}
+
+	for _, c := range cases {
+		hosts, err := parseCnameEntry(c.entry)
+		assert.Nil(t, err, c.entry)
+		assert.Equal(t, c.hosts, hosts, c.entry)
+	}
+}
+
+func (suite *PiholeTestSuite) TestParseInvalidCnameEntry() {
+	t := suite.T()
+	for _, entry := range []string{"test-one.example.io", "test-one.example.io,", ",proxy.example.io"} {
+		hosts, err := parseCnameEntry(entry)
+		var parseErr *ParseError
+		assert.ErrorAs(t, err, &parseErr, entry)
+		assert.Nil(t, hosts, entry)
+	}
+}
+
+func (suite *PiholeTestSuite) TestListEndpointsSkipsInvalidEntries() {
+	t := suite.T()
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(RecordsResponse{
+			Config: RecordsConfig{
+				DNS: DNS{
+					Hosts: []string{
+						"1.1.1.1",
+						"10.0.0.5 nas nas.lan",
+					},
+				},
+			},
+		})
+	})
+	defer server.Close()
+
+	cl, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "correct",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := cl.listRecordsOfTypes(context.Background(), endpoint.RecordTypeA)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "nas", records[0].DNSName)
+	assert.Equal(t, "nas.lan", records[1].DNSName)
+}
@@ -2,50 +2,226 @@ package pihole
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/scaleway/scaleway-sdk-go/logger"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 	"sigs.k8s.io/external-dns/provider"
+	"slices"
+	"sync"
 )
 
+// PiholeProvider manages records in Pi-hole. Until it is connected to the Pi-hole, it keeps trying
+// to connect in the background and fails requests with ErrNotReady.
 type PiholeProvider struct {
 	provider.BaseProvider
-	api piholeApi
+	mu      sync.RWMutex
+	api     piholeApi
+	connErr error
+	cfg     Config
+	cache   *recordsCache
+	cancel  context.CancelFunc
+	closed  bool
 }
 
-// NewPiholeProvider initializes a new PiHole Local DNS based Provider
+// NewPiholeProvider initializes a new PiHole Local DNS based Provider. Configuration errors, and
+// failures to connect that retrying cannot fix such as rejected credentials, are returned: when the
+// Pi-hole cannot be reached, the provider starts without a connection and connects in the
+// background.
 func NewPiholeProvider(cfg Config) (*PiholeProvider, error) {
-	api, err := newPiholeClient(cfg)
-	if err != nil {
+	if err := validateConfig(cfg); err != nil {
 		return nil, err
 	}
-	return &PiholeProvider{api: api}, nil
+
+	p := &PiholeProvider{cfg: cfg, cache: newRecordsCache(cfg.CacheTTL)}
+	if err := p.connect(); err != nil {
+		if !transient(err) {
+			return nil, err
+		}
+		logger.Errorf("Unable to connect to Pi-hole, retrying in the background: %v", err)
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		go p.reconnect(ctx)
+	}
+	return p, nil
+}
+
+// connect creates the Pi-hole client and adjusts the configuration to the capabilities of the
+// server.
+func (p *PiholeProvider) connect() error {
+	api, err := newPiholeClient(p.cfg)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return api.close(context.Background())
+	}
+	if p.cfg.ManagePTR && !api.capabilities().DnsmasqLines {
+		logger.Warningf("Disabling PTR management, which requires dnsmasq lines this Pi-hole does not support")
+		p.cfg.ManagePTR = false
+	}
+	if _, ok := api.(bulkApi); p.cfg.BulkApply && !ok {
+		logger.Warningf("Disabling bulk apply, which is not supported by this Pi-hole API")
+		p.cfg.BulkApply = false
+	}
+	p.api = api
+	return nil
+}
+
+// reconnect keeps trying to connect, backing off according to the retry policy, until it succeeds,
+// fails in a way retrying cannot fix, or the context is done. A failure that cannot be fixed is
+// kept, so the provider stays unready and reports it to every request.
+func (p *PiholeProvider) reconnect(ctx context.Context) {
+	retry := p.cfg.Retry.normalized()
+	for attempt := 1; ; attempt++ {
+		if err := sleepContext(ctx, retry.backoff(attempt)); err != nil {
+			return
+		}
+		err := p.connect()
+		if err == nil {
+			logger.Infof("Connected to Pi-hole")
+			return
+		}
+		if !transient(err) {
+			logger.Errorf("Unable to connect to Pi-hole, giving up: %v", err)
+			p.mu.Lock()
+			p.connErr = err
+			p.mu.Unlock()
+			return
+		}
+		logger.Errorf("Unable to connect to Pi-hole: %v", err)
+	}
+}
+
+// transient reports whether connecting failed for a reason that may go away by itself, such as the
+// Pi-hole being unreachable, still starting or answering with an error. Only rejected credentials
+// and unsupported versions need the configuration or the Pi-hole to be fixed instead.
+func transient(err error) bool {
+	for _, permanent := range []error{
+		ErrUnauthorized,
+		ErrTOTPRequired,
+		ErrTOTPRejected,
+		ErrUnsupportedServerVersion,
+		ErrUnsupportedAPIVersion,
+	} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready reports whether the provider is connected to the Pi-hole.
+func (p *PiholeProvider) Ready() bool {
+	_, err := p.client()
+	return err == nil
+}
+
+// client returns the Pi-hole client, or ErrNotReady while the provider is not connected, along
+// with the failure that made it give up connecting. The configuration is final once the client is
+// set, so it can be read freely after a call succeeded.
+func (p *PiholeProvider) client() (piholeApi, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.connErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotReady, p.connErr)
+	}
+	if p.api == nil {
+		return nil, ErrNotReady
+	}
+	return p.api, nil
+}
+
+// recordTypes lists the record types managed in Pi-hole.
+var recordTypes = []string{
+	endpoint.RecordTypeA,
+	endpoint.RecordTypeAAAA,
+	endpoint.RecordTypeCNAME,
+	endpoint.RecordTypeTXT,
+	endpoint.RecordTypeSRV,
+	endpoint.RecordTypeMX,
+	endpoint.RecordTypeNS,
 }
 
 func (p *PiholeProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
-	aRecords, err := p.api.listRecords(ctx, endpoint.RecordTypeA)
+	api, err := p.client()
 	if err != nil {
 		return nil, err
 	}
-	aaaRecords, err := p.api.listRecords(ctx, endpoint.RecordTypeAAAA)
+
+	cached, generation, ok := p.cache.get()
+	if ok {
+		return cached, nil
+	}
+
+	types := recordTypes
+	if p.cfg.ManagePTR {
+		types = append(slices.Clip(types), endpoint.RecordTypePTR)
+	}
+
+	records, err := api.listRecordsOfTypes(ctx, types...)
 	if err != nil {
 		return nil, err
 	}
-	cnameRecords, err := p.api.listRecords(ctx, endpoint.RecordTypeCNAME)
+	p.cache.set(generation, records)
+	return records, nil
+}
+
+// AdjustEndpoints drops the TTLs Pi-hole cannot store, so external-dns does not keep planning
+// updates for records whose stored TTL can never match.
+func (p *PiholeProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	api, err := p.client()
 	if err != nil {
 		return nil, err
 	}
-	aRecords = append(aRecords, aaaRecords...)
-	return append(aRecords, cnameRecords...), nil
+
+	cnameTTL := api.capabilities().CnameTTL
+	for _, ep := range endpoints {
+		if ep.RecordType != endpoint.RecordTypeCNAME || !cnameTTL {
+			ep.RecordTTL = 0
+		}
+	}
+	return endpoints, nil
 }
 
 func (p *PiholeProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
-	for _, ep := range changes.Delete {
-		if err := p.api.deleteRecord(ctx, ep); err != nil {
+	api, err := p.client()
+	if err != nil {
+		return err
+	}
+
+	// Even a failed apply may have changed some records, so the cache is dropped either way.
+	defer p.cache.invalidate()
+
+	deletes, creates := planChanges(changes)
+	if bulk, ok := api.(bulkApi); ok && p.cfg.BulkApply {
+		return p.applyBulk(ctx, bulk, deletes, creates)
+	}
+
+	for _, ep := range deletes {
+		if err := p.deleteRecord(ctx, ep); err != nil {
 			logger.Errorf("error deleting record %s: %v", ep.DNSName, err)
 			return err
 		}
 	}
+	for _, ep := range creates {
+		if err := p.createRecord(ctx, ep); err != nil {
+			logger.Errorf("error creating record %s: %v", ep.DNSName, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// planChanges turns the changes into the records to delete and the records to create, in the
+// order they are applied.
+func planChanges(changes *plan.Changes) (deletes, creates []*endpoint.Endpoint) {
+	deletes = append(deletes, changes.Delete...)
 
 	updateNew := make(map[piholeEntryKey]*endpoint.Endpoint)
 	for _, ep := range changes.UpdateNew {
@@ -53,32 +229,136 @@ func (p *PiholeProvider) ApplyChanges(ctx context.Context, changes *plan.Changes
 		updateNew[key] = ep
 	}
 
+	// Only the targets that actually changed are touched, so addresses shared by the old and
+	// new endpoint keep resolving while the update is applied. A changed CNAME TTL rewrites the
+	// whole record, as the TTL is part of every Pi-hole entry.
 	for _, ep := range changes.UpdateOld {
 		key := piholeEntryKey{ep.DNSName, ep.RecordType}
-		if newRecord := updateNew[key]; newRecord != nil {
-			if newRecord.Targets[0] == ep.Targets[0] {
-				delete(updateNew, key)
-				continue
-			}
+		stale := ep
+		if newRecord := updateNew[key]; newRecord != nil && !ttlChanged(ep, newRecord) {
+			stale = withTargets(ep, missingTargets(ep.Targets, newRecord.Targets))
+			updateNew[key] = withTargets(newRecord, missingTargets(newRecord.Targets, ep.Targets))
 		}
-		if err := p.api.deleteRecord(ctx, ep); err != nil {
-			logger.Errorf("error deleting record %s: %v", ep.DNSName, err)
-			return err
+		if len(stale.Targets) > 0 {
+			deletes = append(deletes, stale)
 		}
 	}
 
-	for _, ep := range changes.Create {
-		if err := p.api.createRecord(ctx, ep); err != nil {
-			logger.Errorf("error creating record %s: %v", ep.DNSName, err)
-			return err
+	creates = append(creates, changes.Create...)
+	for _, ep := range changes.UpdateNew {
+		if fresh := updateNew[piholeEntryKey{ep.DNSName, ep.RecordType}]; len(fresh.Targets) > 0 {
+			creates = append(creates, fresh)
 		}
 	}
-	for _, ep := range updateNew {
-		if err := p.api.createRecord(ctx, ep); err != nil {
-			logger.Errorf("error creating record %s: %v", ep.DNSName, err)
-			return err
+	return deletes, creates
+}
+
+// applyBulk applies every change, along with the matching PTR records, in a single update.
+func (p *PiholeProvider) applyBulk(ctx context.Context, bulk bulkApi, deletes, creates []*endpoint.Endpoint) error {
+	deletes, err := p.withPTRs(deletes)
+	if err != nil {
+		return err
+	}
+	creates, err = p.withPTRs(creates)
+	if err != nil {
+		return err
+	}
+	if err := bulk.applyChanges(ctx, deletes, creates); err != nil {
+		logger.Errorf("error applying changes: %v", err)
+		return err
+	}
+	return nil
+}
+
+// withPTRs returns the endpoints followed by the PTR records maintained for them.
+func (p *PiholeProvider) withPTRs(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	result := slices.Clip(endpoints)
+	for _, ep := range endpoints {
+		ptrs, err := p.ptrsFor(ep)
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, ptrs...)
+	}
+	return result, nil
+}
+
+// Close stops connecting in the background and releases the resources the provider holds on the
+// Pi-hole server, logging out of its session.
+func (p *PiholeProvider) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	if p.cancel != nil {
+		p.cancel()
 	}
+	api := p.api
+	p.mu.Unlock()
 
+	if api == nil {
+		return nil
+	}
+	return api.close(ctx)
+}
+
+// createRecord creates the record of an endpoint along with its PTR records.
+func (p *PiholeProvider) createRecord(ctx context.Context, ep *endpoint.Endpoint) error {
+	if err := p.api.createRecord(ctx, ep); err != nil {
+		return err
+	}
+	return p.managePTR(ctx, ep, p.api.createRecord)
+}
+
+// deleteRecord deletes the record of an endpoint along with its PTR records.
+func (p *PiholeProvider) deleteRecord(ctx context.Context, ep *endpoint.Endpoint) error {
+	if err := p.api.deleteRecord(ctx, ep); err != nil {
+		return err
+	}
+	return p.managePTR(ctx, ep, p.api.deleteRecord)
+}
+
+// managePTR applies action to the PTR records matching the addresses of an A or AAAA endpoint,
+// keeping them in lockstep with the endpoint when PTR management is enabled.
+func (p *PiholeProvider) managePTR(ctx context.Context, ep *endpoint.Endpoint, action func(context.Context, *endpoint.Endpoint) error) error {
+	ptrs, err := p.ptrsFor(ep)
+	if err != nil {
+		return err
+	}
+	for _, ptr := range ptrs {
+		if err := action(ctx, ptr); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// ptrsFor returns the PTR records maintained for an endpoint, if any.
+func (p *PiholeProvider) ptrsFor(ep *endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	if !p.cfg.ManagePTR || !isAddressRecordType(ep.RecordType) || isWildcard(ep.DNSName) {
+		return nil, nil
+	}
+	return ptrEndpoints(ep)
+}
+
+// ttlChanged reports whether an update changes the TTL of a record type whose TTL is stored in
+// Pi-hole, which requires every entry of the record to be rewritten.
+func ttlChanged(old, new *endpoint.Endpoint) bool {
+	return old.RecordType == endpoint.RecordTypeCNAME && old.RecordTTL != new.RecordTTL
+}
+
+// withTargets returns a copy of the endpoint restricted to the given targets.
+func withTargets(ep *endpoint.Endpoint, targets endpoint.Targets) *endpoint.Endpoint {
+	out := ep.DeepCopy()
+	out.Targets = targets
+	return out
+}
+
+// missingTargets returns the targets of source that are not present in other.
+func missingTargets(source, other endpoint.Targets) endpoint.Targets {
+	missing := endpoint.Targets{}
+	for _, target := range source {
+		if !slices.Contains(other, target) {
+			missing = append(missing, target)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,125 @@
+package pihole
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// fakeApi records the calls made by the provider against the piholeApi.
+type fakeApi struct {
+	records map[string][]*endpoint.Endpoint
+	created []*endpoint.Endpoint
+	deleted []*endpoint.Endpoint
+	caps    Capabilities
+	lists   int
+}
+
+func (f *fakeApi) listRecordsOfTypes(_ context.Context, rtypes ...string) ([]*endpoint.Endpoint, error) {
+	f.lists++
+	records := []*endpoint.Endpoint{}
+	for _, rtype := range rtypes {
+		records = append(records, f.records[rtype]...)
+	}
+	return records, nil
+}
+
+func (f *fakeApi) createRecord(_ context.Context, ep *endpoint.Endpoint) error {
+	f.created = append(f.created, ep)
+	return nil
+}
+
+func (f *fakeApi) deleteRecord(_ context.Context, ep *endpoint.Endpoint) error {
+	f.deleted = append(f.deleted, ep)
+	return nil
+}
+
+func (f *fakeApi) capabilities() Capabilities {
+	return f.caps
+}
+
+func (f *fakeApi) close(_ context.Context) error {
+	return nil
+}
+
+func (suite *PiholeTestSuite) TestApplyChangesUpdatesOnlyChangedTargets() {
+	t := suite.T()
+	api := &fakeApi{}
+	p := &PiholeProvider{api: api}
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "2.2.2.2", "3.3.3.3"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, api.deleted, 1)
+	assert.Equal(t, endpoint.Targets{"1.1.1.1"}, api.deleted[0].Targets)
+	assert.Len(t, api.created, 1)
+	assert.Equal(t, endpoint.Targets{"3.3.3.3"}, api.created[0].Targets)
+}
+
+func (suite *PiholeTestSuite) TestApplyChangesSkipsUnchangedTargets() {
+	t := suite.T()
+	api := &fakeApi{}
+	p := &PiholeProvider{api: api}
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "1.1.1.1", "2.2.2.2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("test-one.example.io", endpoint.RecordTypeA, "2.2.2.2", "1.1.1.1"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, api.deleted)
+	assert.Empty(t, api.created)
+}
+
+func (suite *PiholeTestSuite) TestApplyChangesRewritesCnameOnTTLChange() {
+	t := suite.T()
+	api := &fakeApi{}
+	p := &PiholeProvider{api: api}
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("test-one.example.io", endpoint.RecordTypeCNAME, 300, "proxy.example.io"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("test-one.example.io", endpoint.RecordTypeCNAME, 600, "proxy.example.io"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, api.deleted, 1)
+	assert.Equal(t, endpoint.TTL(300), api.deleted[0].RecordTTL)
+	assert.Len(t, api.created, 1)
+	assert.Equal(t, endpoint.TTL(600), api.created[0].RecordTTL)
+}
+
+func (suite *PiholeTestSuite) TestAdjustEndpointsDropsUnsupportedTTLs() {
+	t := suite.T()
+	p := &PiholeProvider{api: &fakeApi{caps: Capabilities{CnameTTL: true}}}
+
+	endpoints, err := p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("test-one.example.io", endpoint.RecordTypeA, 300, "1.1.1.1"),
+		endpoint.NewEndpointWithTTL("test-two.example.io", endpoint.RecordTypeCNAME, 300, "test-one.example.io"),
+	})
+
+	assert.Nil(t, err)
+	assert.False(t, endpoints[0].RecordTTL.IsConfigured())
+	assert.Equal(t, endpoint.TTL(300), endpoints[1].RecordTTL)
+
+	p = &PiholeProvider{api: &fakeApi{}}
+	endpoints, _ = p.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("test-two.example.io", endpoint.RecordTypeCNAME, 300, "test-one.example.io"),
+	})
+	assert.False(t, endpoints[0].RecordTTL.IsConfigured())
+}
@@ -0,0 +1,51 @@
+package pihole
+
+import (
+	"fmt"
+	"net/netip"
+	"sigs.k8s.io/external-dns/endpoint"
+	"strconv"
+	"strings"
+)
+
+// reverseName returns the in-addr.arpa or ip6.arpa name of an IPv4 or IPv6 address.
+func reverseName(address string) (string, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return "", err
+	}
+	addr = addr.WithZone("")
+
+	var labels []string
+	if addr.Is4() {
+		octets := addr.As4()
+		for i := len(octets) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(octets[i])))
+		}
+		return strings.Join(labels, ".") + ".in-addr.arpa", nil
+	}
+
+	bytes := addr.As16()
+	for i := len(bytes) - 1; i >= 0; i-- {
+		labels = append(labels, strconv.FormatUint(uint64(bytes[i]&0x0f), 16), strconv.FormatUint(uint64(bytes[i]>>4), 16))
+	}
+	return strings.Join(labels, ".") + ".ip6.arpa", nil
+}
+
+// ptrEndpoints returns one PTR endpoint per address of an A or AAAA endpoint, each pointing back
+// to the endpoint name.
+func ptrEndpoints(ep *endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	ptrs := make([]*endpoint.Endpoint, 0, len(ep.Targets))
+	for _, target := range ep.Targets {
+		name, err := reverseName(target)
+		if err != nil {
+			return nil, fmt.Errorf("unable to build PTR record for %s -> %s: %w", ep.DNSName, target, err)
+		}
+		ptrs = append(ptrs, &endpoint.Endpoint{
+			DNSName:    name,
+			Targets:    []string{ep.DNSName},
+			RecordType: endpoint.RecordTypePTR,
+		})
+	}
+	return ptrs, nil
+}
@@ -0,0 +1,97 @@
+package pihole
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func (suite *PiholeTestSuite) TestReverseName() {
+	t := suite.T()
+	cases := map[string]string{
+		"10.0.4.12":          "12.4.0.10.in-addr.arpa",
+		"2001:db8::567:89ab": "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+		"fe80::1%eth0":       "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.e.f.ip6.arpa",
+	}
+
+	for address, expected := range cases {
+		name, err := reverseName(address)
+		assert.Nil(t, err, address)
+		assert.Equal(t, expected, name, address)
+	}
+
+	_, err := reverseName("app.example.io")
+	assert.NotNil(t, err)
+}
+
+func (suite *PiholeTestSuite) TestListPtrEndpoints() {
+	t := suite.T()
+	lines := []string{
+		dnsmasqBlockBegin,
+		"ptr-record=12.4.0.10.in-addr.arpa,app.example.io",
+		"ptr-record=13.4.0.10.in-addr.arpa,app.example.com",
+		dnsmasqBlockEnd,
+	}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:       server.URL,
+		Password:     "password",
+		DomainFilter: endpoint.NewDomainFilter([]string{"example.io"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypePTR)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "12.4.0.10.in-addr.arpa", records[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"app.example.io"}, records[0].Targets)
+}
+
+func (suite *PiholeTestSuite) TestApplyChangesManagesPtrRecords() {
+	t := suite.T()
+	api := &fakeApi{}
+	p := &PiholeProvider{api: api, cfg: Config{ManagePTR: true}}
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("app.example.io", endpoint.RecordTypeA, "10.0.4.12"),
+			endpoint.NewEndpoint("proxy.example.io", endpoint.RecordTypeCNAME, "app.example.io"),
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("db.example.io", endpoint.RecordTypeAAAA, "2001:db8::1", "2001:db8::2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("db.example.io", endpoint.RecordTypeAAAA, "2001:db8::2"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, api.created, 3)
+	assert.Equal(t, endpoint.RecordTypePTR, api.created[1].RecordType)
+	assert.Equal(t, "12.4.0.10.in-addr.arpa", api.created[1].DNSName)
+	assert.Equal(t, endpoint.Targets{"app.example.io"}, api.created[1].Targets)
+	assert.Len(t, api.deleted, 2)
+	assert.Equal(t, endpoint.RecordTypePTR, api.deleted[1].RecordType)
+	assert.Equal(t, endpoint.Targets{"db.example.io"}, api.deleted[1].Targets)
+}
+
+func (suite *PiholeTestSuite) TestApplyChangesWithoutPtrManagement() {
+	t := suite.T()
+	api := &fakeApi{}
+	p := &PiholeProvider{api: api}
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("app.example.io", endpoint.RecordTypeA, "10.0.4.12"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, api.created, 1)
+}
@@ -0,0 +1,139 @@
+package pihole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how calls to the Pi-hole server are retried. Every attempt, including the
+// one made after logging in again on an expired session, counts against MaxAttempts. A call whose
+// Retry-After delay exceeds MaxBackoff fails instead of waiting that long.
+type RetryPolicy struct {
+	MaxAttempts    int           `env:"PIHOLE_RETRY_MAX_ATTEMPTS" envDefault:"4"`
+	InitialBackoff time.Duration `env:"PIHOLE_RETRY_INITIAL_BACKOFF" envDefault:"500ms"`
+	MaxBackoff     time.Duration `env:"PIHOLE_RETRY_MAX_BACKOFF" envDefault:"10s"`
+	StatusCodes    []int         `env:"PIHOLE_RETRY_STATUS_CODES" envDefault:"429,502,503,504"`
+}
+
+// defaultRetryPolicy mirrors the environment defaults, and fills in any field left unset.
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	StatusCodes:    []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+// useBackoff asks for the exponential backoff delay of the policy before the next attempt.
+const useBackoff time.Duration = -1
+
+// retryableError marks a failed attempt that may be retried after the given delay.
+type retryableError struct {
+	err    error
+	reason string
+	delay  time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+func (r RetryPolicy) normalized() RetryPolicy {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = defaultRetryPolicy.MaxAttempts
+	}
+	if r.InitialBackoff <= 0 {
+		r.InitialBackoff = defaultRetryPolicy.InitialBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = defaultRetryPolicy.MaxBackoff
+	}
+	if r.StatusCodes == nil {
+		r.StatusCodes = defaultRetryPolicy.StatusCodes
+	}
+	return r
+}
+
+// retryable reports whether a response with the given status code may be retried.
+func (r RetryPolicy) retryable(statusCode int) bool {
+	return slices.Contains(r.normalized().StatusCodes, statusCode)
+}
+
+// backoff returns the delay before the attempt following the given one: an exponentially growing
+// delay capped at MaxBackoff, of which the upper half is randomized to spread out retries.
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	delay := r.MaxBackoff
+	if shift := attempt - 1; shift < 32 && r.InitialBackoff<<shift < r.MaxBackoff {
+		delay = r.InitialBackoff << shift
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// run calls attempt until it succeeds, fails with an error that cannot be retried, or the
+// policy runs out of attempts.
+func (r RetryPolicy) run(ctx context.Context, attempt func() error) error {
+	r = r.normalized()
+	for n := 1; ; n++ {
+		err := attempt()
+		var retry *retryableError
+		if !errors.As(err, &retry) {
+			return err
+		}
+		if n >= r.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", n, retry.err)
+		}
+
+		delay := retry.delay
+		if delay == useBackoff {
+			delay = r.backoff(n)
+		}
+		if delay > r.MaxBackoff {
+			return fmt.Errorf("giving up, pihole asked to retry in %s: %w", delay, retry.err)
+		}
+		retriesTotal.WithLabelValues(retry.reason).Inc()
+		logger.Debugf("Retrying pihole request in %s after %v", delay, retry.err)
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext waits for the given delay, returning early with the context error once the context
+// is done.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter returns the delay requested by a Retry-After header, given either in seconds or as
+// an HTTP date, or useBackoff when there is none.
+func retryAfter(res *http.Response) time.Duration {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return useBackoff
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return useBackoff
+}
@@ -0,0 +1,203 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+	"time"
+)
+
+var fastRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     2 * time.Millisecond,
+}
+
+func (suite *PiholeTestSuite) TestRetryOnUnavailable() {
+	t := suite.T()
+	calls := 0
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(RecordsResponse{
+			Config: RecordsConfig{DNS: DNS{CnameRecords: []string{"test-one.example.io,proxy.example.io"}}},
+		})
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+		Retry:    fastRetryPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 3, calls)
+}
+
+func (suite *PiholeTestSuite) TestRetryGivesUp() {
+	t := suite.T()
+	calls := 0
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+		Retry:    fastRetryPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.ErrorContains(t, err, "giving up after 3 attempts")
+	assert.Equal(t, 3, calls)
+}
+
+func (suite *PiholeTestSuite) TestNoRetryOnClientError() {
+	t := suite.T()
+	calls := 0
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+		Retry:    fastRetryPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func (suite *PiholeTestSuite) TestReloginIsBounded() {
+	t := suite.T()
+	logins, calls := 0, 0
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/auth" {
+			logins++
+			_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Sid: "sid", Valid: true}})
+			return
+		}
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	defer server.Close()
+
+	client := &piholeClient{
+		cfg:        Config{Server: server.URL, Retry: fastRetryPolicy},
+		httpClient: &http.Client{},
+		auth:       &passwordAuth{},
+	}
+
+	_, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.ErrorContains(t, err, "giving up after 3 attempts")
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 3, logins)
+}
+
+func (suite *PiholeTestSuite) TestReloginFailuresShareRetryBudget() {
+	t := suite.T()
+	logins, calls := 0, 0
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/auth" {
+			logins++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	defer server.Close()
+
+	client := &piholeClient{
+		cfg:        Config{Server: server.URL, Retry: fastRetryPolicy},
+		httpClient: &http.Client{},
+		auth:       &passwordAuth{},
+	}
+
+	_, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.ErrorContains(t, err, "giving up after 3 attempts")
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 3, logins)
+}
+
+func (suite *PiholeTestSuite) TestRetryAfterBeyondMaxBackoff() {
+	t := suite.T()
+	calls := 0
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+		Retry:    RetryPolicy{MaxAttempts: 3, MaxBackoff: time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.ErrorContains(t, err, "pihole asked to retry in 1h0m0s")
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func (suite *PiholeTestSuite) TestRetryAfter() {
+	t := suite.T()
+
+	res := &http.Response{Header: http.Header{}}
+	assert.Equal(t, useBackoff, retryAfter(res))
+
+	res.Header.Set("Retry-After", "3")
+	assert.Equal(t, 3*time.Second, retryAfter(res))
+
+	res.Header.Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.Equal(t, time.Duration(0), retryAfter(res))
+
+	res.Header.Set("Retry-After", "soon")
+	assert.Equal(t, useBackoff, retryAfter(res))
+}
+
+func (suite *PiholeTestSuite) TestBackoff() {
+	t := suite.T()
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: time.Second, 100: time.Second} {
+		delay := policy.backoff(attempt)
+		assert.GreaterOrEqual(t, delay, ceiling/2)
+		assert.LessOrEqual(t, delay, ceiling)
+	}
+}
@@ -0,0 +1,156 @@
+package pihole
+
+import (
+	"context"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"net/http"
+	"time"
+)
+
+// sessionRefreshMargin is how long before its expiry a session is renewed, so that requests do not
+// race the expiry of the session they are sent with.
+const sessionRefreshMargin = 10 * time.Second
+
+// sessionLogin is a login in progress, whose result is shared by every caller waiting for it.
+type sessionLogin struct {
+	done chan struct{}
+	err  error
+}
+
+// currentSession returns the session requests are sent with.
+func (p *piholeClient) currentSession() *Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.session
+}
+
+// retrieveNewToken obtains a new session from the authenticator, replacing the current one, and
+// retries the login according to the configured retry policy.
+func (p *piholeClient) retrieveNewToken(ctx context.Context) error {
+	return p.cfg.Retry.run(ctx, func() error {
+		return p.renewSession(ctx, p.currentSession())
+	})
+}
+
+// renewSession replaces the stale session with a new one. Concurrent callers are coalesced into
+// a single login, as every login creates a session on the Pi-hole: callers arriving while a login
+// is in progress wait for its result, and callers whose stale session was already replaced return
+// right away.
+func (p *piholeClient) renewSession(ctx context.Context, stale *Session) error {
+	p.mu.Lock()
+	if login := p.login; login != nil {
+		p.mu.Unlock()
+		select {
+		case <-login.done:
+			return login.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if p.session != stale {
+		p.mu.Unlock()
+		return nil
+	}
+	login := &sessionLogin{done: make(chan struct{})}
+	p.login = login
+	p.mu.Unlock()
+
+	session, err := p.auth.login(ctx, p)
+
+	p.mu.Lock()
+	if err == nil {
+		p.session = session
+		p.sessionExpires = time.Time{}
+		p.touchSessionLocked()
+	}
+	p.login = nil
+	p.mu.Unlock()
+	login.err = err
+	close(login.done)
+
+	if err != nil {
+		sessionValid.Set(0)
+		return err
+	}
+	if session != nil && session.Valid {
+		sessionValid.Set(1)
+	}
+	p.persistSession(session)
+	return nil
+}
+
+// touchSession records that the session was used. Pi-hole extends a session by its validity on
+// every request, so the session expires once it has not been used for that long. Sessions of
+// password-less Pi-holes have no validity and never expire.
+func (p *piholeClient) touchSession() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.touchSessionLocked()
+}
+
+func (p *piholeClient) touchSessionLocked() {
+	if p.session == nil || p.session.Validity <= 0 {
+		return
+	}
+	p.sessionExpires = p.now().Add(time.Duration(p.session.Validity) * time.Second)
+	sessionExpiry.Set(float64(p.sessionExpires.Unix()))
+}
+
+// ensureSession logs in again when the session is about to expire, instead of waiting for Pi-hole
+// to reject a request.
+func (p *piholeClient) ensureSession(ctx context.Context) error {
+	p.mu.Lock()
+	session, expires := p.session, p.sessionExpires
+	p.mu.Unlock()
+
+	if session == nil || expires.IsZero() {
+		return nil
+	}
+	if p.now().Before(expires.Add(-sessionRefreshMargin)) {
+		return nil
+	}
+
+	logger.Debugf("Pi-hole session expires at %s, logging in again", expires.Format(time.RFC3339))
+	sessionRefreshesTotal.Inc()
+	return p.renewSession(ctx, session)
+}
+
+// close logs out, so the session does not count against the maximum number of sessions Pi-hole
+// accepts until it expires. Sessions the webhook did not create are left open, and so are sessions
+// kept in the session file, for the next start to reuse them.
+func (p *piholeClient) close(ctx context.Context) error {
+	session := p.currentSession()
+	if session == nil || session.Sid == "" || !p.auth.ownsSession() {
+		return nil
+	}
+	if p.cfg.SessionFile != "" {
+		logger.Infof("Keeping the Pi-hole session in %s for the next start", p.cfg.SessionFile)
+		return nil
+	}
+
+	logger.Infof("Logging out of Pi-hole")
+	_, err := p.callPihole(ctx, http.MethodDelete, "/auth", nil, nil)
+
+	p.mu.Lock()
+	p.session = nil
+	p.sessionExpires = time.Time{}
+	p.mu.Unlock()
+	sessionValid.Set(0)
+	return err
+}
+
+// setSession authenticates a request with the session ID, sent either in the sid header or in
+// the sid cookie. Pi-hole requires the CSRF token of the session along with the cookie.
+func (p *piholeClient) setSession(req *http.Request, session *Session) {
+	if session == nil || session.Sid == "" {
+		return
+	}
+	if p.cfg.SIDTransport == sidTransportCookie {
+		req.AddCookie(&http.Cookie{Name: "sid", Value: session.Sid})
+		if session.CSRF != "" {
+			req.Header.Set("X-FTL-CSRF", session.CSRF)
+		}
+		return
+	}
+	req.Header.Set("sid", session.Sid)
+}
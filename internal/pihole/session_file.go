@@ -0,0 +1,119 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// persistedSession is the session kept in the session file, so that a restarted webhook can reuse
+// it instead of creating a new one, as Pi-hole limits the number of concurrent sessions.
+type persistedSession struct {
+	Server string `json:"server"`
+	Sid    string `json:"sid"`
+	CSRF   string `json:"csrf,omitempty"`
+}
+
+// restoreSession reuses the session persisted by a previous run when Pi-hole still accepts it,
+// reporting whether it did.
+func (p *piholeClient) restoreSession(ctx context.Context) bool {
+	if p.cfg.SessionFile == "" || !p.auth.ownsSession() {
+		return false
+	}
+
+	stored, err := readSessionFile(p.cfg.SessionFile)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Warningf("Unable to read the Pi-hole session file: %v", err)
+		}
+		return false
+	}
+	if stored.Server != p.cfg.Server || stored.Sid == "" {
+		return false
+	}
+
+	p.mu.Lock()
+	p.session = &Session{Valid: true, Sid: stored.Sid, CSRF: stored.CSRF}
+	p.mu.Unlock()
+
+	var response LoginResponse
+	if _, err := p.callPihole(ctx, http.MethodGet, "/auth", nil, &response); err != nil || !response.Session.Valid {
+		logger.Infof("The persisted Pi-hole session is no longer valid, logging in again")
+		p.mu.Lock()
+		p.session = nil
+		p.mu.Unlock()
+		return false
+	}
+
+	logger.Infof("Reusing the persisted Pi-hole session")
+	p.mu.Lock()
+	p.session = &Session{Valid: true, Sid: stored.Sid, CSRF: stored.CSRF, Validity: response.Session.Validity}
+	p.touchSessionLocked()
+	p.mu.Unlock()
+	sessionValid.Set(1)
+	return true
+}
+
+// persistSession writes the session to the session file. Failing to do so only costs a new
+// session on the next start, so errors are logged rather than returned.
+func (p *piholeClient) persistSession(session *Session) {
+	if p.cfg.SessionFile == "" || !p.auth.ownsSession() || session == nil || session.Sid == "" {
+		return
+	}
+
+	stored := persistedSession{Server: p.cfg.Server, Sid: session.Sid, CSRF: session.CSRF}
+	if err := writeSessionFile(p.cfg.SessionFile, stored); err != nil {
+		logger.Warningf("Unable to write the Pi-hole session file: %v", err)
+	}
+}
+
+func readSessionFile(path string) (persistedSession, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return persistedSession{}, err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		logger.Warningf("The Pi-hole session file %s is accessible by other users, it is restricted on the next write", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return persistedSession{}, err
+	}
+	var stored persistedSession
+	err = json.Unmarshal(data, &stored)
+	return stored, err
+}
+
+// writeSessionFile replaces the session file with one only readable by its owner. The session is
+// written to a temporary file first, so a crash never leaves a partially written file behind.
+func writeSessionFile(path string, stored persistedSession) error {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := file.Chmod(0o600); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
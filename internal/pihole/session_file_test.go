@@ -0,0 +1,125 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+
+// sessionServer issues a new session ID for every login, and only accepts the sessions in valid.
+func (suite *PiholeTestSuite) sessionServer(valid map[string]bool, logins *int) *httptest.Server {
+	return suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/info/version":
+			_ = json.NewEncoder(w).Encode(VersionResponse{})
+		case r.URL.Path == "/api/auth" && r.Method == http.MethodPost:
+			*logins++
+			sid := fmt.Sprintf("sid-%d", *logins)
+			valid[sid] = true
+			_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Sid: sid, Valid: true, Validity: 300}})
+		case r.URL.Path == "/api/auth" && r.Method == http.MethodGet:
+			if !valid[r.Header.Get("sid")] {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(LoginResponse{})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Valid: true, Validity: 300}})
+		case r.URL.Path == "/api/auth" && r.Method == http.MethodDelete:
+			delete(valid, r.Header.Get("sid"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+func (suite *PiholeTestSuite) TestSessionFileReused() {
+	t := suite.T()
+	logins := 0
+	server := suite.sessionServer(map[string]bool{}, &logins)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+	cfg := Config{Server: server.URL, Password: "password", SessionFile: path}
+
+	first, err := newPiholeClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	second, err := newPiholeClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, logins)
+	assert.Equal(t, first.(*piholeClient).session.Sid, second.(*piholeClient).session.Sid)
+	assert.False(t, second.(*piholeClient).sessionExpires.IsZero())
+}
+
+func (suite *PiholeTestSuite) TestSessionFileExpired() {
+	t := suite.T()
+	logins := 0
+	server := suite.sessionServer(map[string]bool{}, &logins)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+	assert.Nil(t, writeSessionFile(path, persistedSession{Server: server.URL, Sid: "expired"}))
+
+	client, err := newPiholeClient(Config{Server: server.URL, Password: "password", SessionFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, logins)
+	stored, err := readSessionFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, client.(*piholeClient).session.Sid, stored.Sid)
+}
+
+func (suite *PiholeTestSuite) TestSessionFileOfAnotherServer() {
+	t := suite.T()
+	logins := 0
+	server := suite.sessionServer(map[string]bool{"other": true}, &logins)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+	assert.Nil(t, writeSessionFile(path, persistedSession{Server: "http://other.lan", Sid: "other"}))
+
+	client, err := newPiholeClient(Config{Server: server.URL, Password: "password", SessionFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, logins)
+	assert.NotEqual(t, "other", client.(*piholeClient).session.Sid)
+}
+
+func (suite *PiholeTestSuite) TestSessionFileKeptOnShutdown() {
+	t := suite.T()
+	logins := 0
+	valid := map[string]bool{}
+	server := suite.sessionServer(valid, &logins)
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+	cfg := Config{Server: server.URL, Password: "password", SessionFile: path}
+
+	first, err := newPiholeClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, first.close(context.Background()))
+
+	second, err := newPiholeClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, logins)
+	assert.Equal(t, map[string]bool{"sid-1": true}, valid)
+	assert.Equal(t, "sid-1", second.(*piholeClient).session.Sid)
+}
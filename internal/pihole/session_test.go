@@ -0,0 +1,164 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sync"
+	"time"
+)
+
+func (suite *PiholeTestSuite) TestSessionRefreshedBeforeExpiry() {
+	t := suite.T()
+	logins := 0
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/auth":
+			logins++
+			_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Sid: "sid", Valid: true, Validity: 300}})
+		case "/api/info/version":
+			_ = json.NewEncoder(w).Encode(VersionResponse{})
+		default:
+			_ = json.NewEncoder(w).Encode(RecordsResponse{})
+		}
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := client.(*piholeClient)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	p.touchSession()
+
+	// Every request extends the session, so it is kept as long as it is in use.
+	now = now.Add(4 * time.Minute)
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, logins)
+	assert.Equal(t, now.Add(5*time.Minute), p.sessionExpires)
+
+	now = now.Add(5*time.Minute - sessionRefreshMargin)
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, logins)
+}
+
+func (suite *PiholeTestSuite) TestSessionWithoutValidity() {
+	t := suite.T()
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(RecordsResponse{})
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, client.(*piholeClient).sessionExpires.IsZero())
+}
+
+func (suite *PiholeTestSuite) TestCloseLogsOut() {
+	t := suite.T()
+	var logout *http.Request
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/auth":
+			logout = r
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/auth":
+			_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Sid: "sid", Valid: true, Validity: 300}})
+		case r.URL.Path == "/api/info/version":
+			_ = json.NewEncoder(w).Encode(VersionResponse{})
+		}
+	})
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, p.Close(context.Background()))
+	if assert.NotNil(t, logout) {
+		assert.Equal(t, "sid", logout.Header.Get("sid"))
+	}
+
+	// Closing again does not log out a second time.
+	logout = nil
+	assert.Nil(t, p.Close(context.Background()))
+	assert.Nil(t, logout)
+}
+
+func (suite *PiholeTestSuite) TestConcurrentUnauthorizedRequestsLoginOnce() {
+	t := suite.T()
+	var mu sync.Mutex
+	logins := 0
+	current := ""
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/auth":
+			logins++
+			current = fmt.Sprintf("sid-%d", logins)
+			_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Sid: current, Valid: true, Validity: 300}})
+		case "/api/info/version":
+			_ = json.NewEncoder(w).Encode(VersionResponse{})
+		default:
+			if r.Header.Get("sid") != current {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(RecordsResponse{})
+		}
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+		Retry:    fastRetryPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pi-hole drops the session, for example after a restart.
+	mu.Lock()
+	current = ""
+	mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 2, logins)
+}
@@ -0,0 +1,81 @@
+package pihole
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func (suite *PiholeTestSuite) TestListSrvEndpoints() {
+	t := suite.T()
+	lines := []string{
+		dnsmasqBlockBegin,
+		"srv-host=_ldap._tcp.example.io,ldap.example.io,389,10,5",
+		"srv-host=_ldap._tcp.example.io,ldap-two.example.io,389,20,5",
+		"srv-host=_ldap._tcp.example.io,ldap.example.io",
+		dnsmasqBlockEnd,
+	}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeSRV)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "_ldap._tcp.example.io", records[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"10 5 389 ldap.example.io", "20 5 389 ldap-two.example.io"}, records[0].Targets)
+}
+
+func (suite *PiholeTestSuite) TestCreateSrvRecord() {
+	t := suite.T()
+	lines := []string{}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"10 5 389 ldap.example.io"},
+		DNSName:    "_ldap._tcp.example.io",
+		RecordType: endpoint.RecordTypeSRV,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		dnsmasqBlockBegin,
+		"srv-host=_ldap._tcp.example.io,ldap.example.io,389,10,5",
+		dnsmasqBlockEnd,
+	}, lines)
+}
+
+func (suite *PiholeTestSuite) TestCreateInvalidSrvRecord() {
+	t := suite.T()
+	lines := []string{}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"ldap.example.io"},
+		DNSName:    "_ldap._tcp.example.io",
+		RecordType: endpoint.RecordTypeSRV,
+	})
+
+	assert.NotNil(t, err)
+	assert.Empty(t, lines)
+}
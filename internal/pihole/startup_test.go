@@ -0,0 +1,179 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// flakyServer serves a Pi-hole with the up handler, answering 503 Service Unavailable to every
+// request instead while down is set, counting the requests it receives.
+func (suite *PiholeTestSuite) flakyServer(down *atomic.Bool, requests *atomic.Int32, up http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		up(w, r)
+	}
+}
+
+func (suite *PiholeTestSuite) TestProviderStartsWhilePiholeIsDown() {
+	t := suite.T()
+	var down atomic.Bool
+	var requests atomic.Int32
+	down.Store(true)
+	server := suite.newTestServer(suite.flakyServer(&down, &requests, suite.authedHandler(cnameRecords)))
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{Server: server.URL, Password: "password", Retry: fastRetryPolicy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close(context.Background())
+
+	assert.False(t, p.Ready())
+	_, err = p.Records(context.Background())
+	assert.ErrorIs(t, err, ErrNotReady)
+	assert.ErrorIs(t, p.ApplyChanges(context.Background(), nil), ErrNotReady)
+
+	down.Store(false)
+
+	assert.Eventually(t, p.Ready, time.Second, time.Millisecond)
+	records, err := p.Records(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+}
+
+func (suite *PiholeTestSuite) TestProviderRetriesUnexpectedResponses() {
+	t := suite.T()
+
+	for name, failure := range map[string]http.HandlerFunc{
+		"server error": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+		"starting page": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html><body>Pi-hole is starting</body></html>"))
+		},
+	} {
+		var down atomic.Bool
+		down.Store(true)
+		up := suite.authedHandler(cnameRecords)
+		server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				failure(w, r)
+				return
+			}
+			up(w, r)
+		})
+
+		p, err := NewPiholeProvider(Config{Server: server.URL, Password: "password", Retry: fastRetryPolicy})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		assert.False(t, p.Ready(), name)
+
+		down.Store(false)
+
+		assert.Eventually(t, p.Ready, time.Second, time.Millisecond, name)
+		_ = p.Close(context.Background())
+		server.Close()
+	}
+}
+
+func (suite *PiholeTestSuite) TestProviderCloseStopsConnecting() {
+	t := suite.T()
+	var down atomic.Bool
+	var requests atomic.Int32
+	down.Store(true)
+	server := suite.newTestServer(suite.flakyServer(&down, &requests, suite.authedHandler(cnameRecords)))
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{Server: server.URL, Password: "password", Retry: fastRetryPolicy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return requests.Load() > 3 }, time.Second, time.Millisecond)
+
+	assert.Nil(t, p.Close(context.Background()))
+	// A connection attempt in flight when the provider is closed may still finish.
+	time.Sleep(20 * time.Millisecond)
+	stopped := requests.Load()
+	down.Store(false)
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, stopped, requests.Load())
+	assert.False(t, p.Ready())
+}
+
+func (suite *PiholeTestSuite) TestProviderRejectsInvalidConfig() {
+	t := suite.T()
+
+	_, err := NewPiholeProvider(Config{})
+	assert.ErrorIs(t, err, ErrNoPiholeServer)
+
+	_, err = NewPiholeProvider(Config{Server: "http://pihole.invalid", AuthMode: "kerberos"})
+	assert.ErrorIs(t, err, ErrUnsupportedAuthMode)
+}
+
+// rejectingServer is a flakyServer rejecting every login, counting the logins it receives.
+func (suite *PiholeTestSuite) rejectingServer(down *atomic.Bool, requests, logins *atomic.Int32) http.HandlerFunc {
+	authed := suite.authedHandler(cnameRecords)
+	return suite.flakyServer(down, requests, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/auth" {
+			authed(w, r)
+			return
+		}
+		logins.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(ErrorResponse{Error: ErrorDetail{Key: "unauthorized", Message: "Unauthorized"}})
+	})
+}
+
+func (suite *PiholeTestSuite) TestProviderFailsOnRejectedCredentials() {
+	t := suite.T()
+	var down atomic.Bool
+	var requests, logins atomic.Int32
+	server := suite.newTestServer(suite.rejectingServer(&down, &requests, &logins))
+	defer server.Close()
+
+	_, err := NewPiholeProvider(Config{Server: server.URL, Password: "wrong", Retry: fastRetryPolicy})
+
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, int32(1), logins.Load())
+}
+
+func (suite *PiholeTestSuite) TestProviderStopsConnectingOnRejectedCredentials() {
+	t := suite.T()
+	var down atomic.Bool
+	var requests, logins atomic.Int32
+	down.Store(true)
+	server := suite.newTestServer(suite.rejectingServer(&down, &requests, &logins))
+	defer server.Close()
+
+	p, err := NewPiholeProvider(Config{Server: server.URL, Password: "wrong", Retry: fastRetryPolicy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close(context.Background())
+	down.Store(false)
+
+	assert.Eventually(t, func() bool {
+		_, err := p.Records(context.Background())
+		return errors.Is(err, ErrUnauthorized)
+	}, time.Second, time.Millisecond)
+	stopped := requests.Load()
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, stopped, requests.Load())
+	assert.Equal(t, int32(1), logins.Load())
+	assert.False(t, p.Ready())
+	_, err = p.Records(context.Background())
+	assert.ErrorIs(t, err, ErrNotReady)
+}
@@ -3,6 +3,7 @@ package pihole
 import (
 	"context"
 	"encoding/json"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"net/http"
 	"net/http/httptest"
@@ -27,7 +28,23 @@ func (suite *PiholeTestSuite) newTestServer(hdlr http.HandlerFunc) *httptest.Ser
 }
 
 func (suite *PiholeTestSuite) authedServer(hndlr http.HandlerFunc) *httptest.Server {
-	return suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+	return suite.newTestServer(suite.authedHandler(hndlr))
+}
+
+// authedHandler answers the version and login requests of a Pi-hole v6 server, passing every
+// other request to hndlr.
+func (suite *PiholeTestSuite) authedHandler(hndlr http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/info/version" {
+			_ = json.NewEncoder(w).Encode(VersionResponse{
+				Version: ComponentVersions{
+					Core: ComponentVersion{Local: LocalVersion{Version: "v6.1.0"}},
+					Web:  ComponentVersion{Local: LocalVersion{Version: "v6.1.0"}},
+					FTL:  ComponentVersion{Local: LocalVersion{Version: "v6.1.0"}},
+				},
+			})
+			return
+		}
 		if r.URL.Path == "/api/auth" {
 			_ = json.NewEncoder(w).Encode(LoginResponse{
 				Session: Session{
@@ -40,6 +57,26 @@ func (suite *PiholeTestSuite) authedServer(hndlr http.HandlerFunc) *httptest.Ser
 		}
 		hndlr(w, r)
 		return
+	}
+}
+
+// dnsmasqServer serves misc.dnsmasq_lines from lines, alone or as the whole configuration, and
+// stores the lines sent by PATCH requests.
+func (suite *PiholeTestSuite) dnsmasqServer(lines *[]string) *httptest.Server {
+	t := suite.T()
+	return suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && (r.URL.Path == "/api/config" || r.URL.Path == "/api/config/misc/dnsmasq_lines"):
+			_ = json.NewEncoder(w).Encode(RecordsResponse{
+				Config: RecordsConfig{Misc: Misc{DnsmasqLines: *lines}},
+			})
+		case r.Method == http.MethodPatch && r.URL.Path == "/api/config":
+			var request ConfigRequest
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+			*lines = request.Config.Misc.DnsmasqLines
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
 	})
 }
 
@@ -0,0 +1,145 @@
+package pihole
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// tlsVersions maps the accepted minimum TLS versions to their protocol versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig creates the TLS configuration used to reach the Pi-hole server, loading the CA
+// bundle and client certificate from their files.
+func newTLSConfig(cfg Config) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		ServerName:         cfg.TLSServerName,
+	}
+
+	if cfg.TLSMinVersion != "" {
+		version, ok := tlsVersions[cfg.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported minimum version %q", ErrInvalidTLSConfig, cfg.TLSMinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLSConfig, err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificate found in %s", ErrInvalidTLSConfig, cfg.TLSCAFile)
+		}
+		config.RootCAs = roots
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("%w: the client certificate and key must be set together", ErrInvalidTLSConfig)
+	}
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLSConfig, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// tlsFiles returns the certificate files of the TLS configuration.
+func tlsFiles(cfg Config) []string {
+	var files []string
+	for _, file := range []string{cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// fileStamp identifies a version of a file, so changes to it can be noticed without reading it.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampFiles returns the stamps of the files. A file that cannot be read has a zero stamp.
+func stampFiles(files []string) []fileStamp {
+	stamps := make([]fileStamp, len(files))
+	for i, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+// reloadingTransport sends requests with a transport built from the current certificate files. It
+// builds a new transport whenever one of the files changes, so rotated certificates are picked up
+// without a restart. Files that fail to load, for example because a certificate was replaced
+// before its key, leave the previous transport in place until they change again.
+type reloadingTransport struct {
+	cfg       Config
+	files     []string
+	mu        sync.Mutex
+	stamps    []fileStamp
+	transport *http.Transport
+}
+
+func newReloadingTransport(cfg Config) (*reloadingTransport, error) {
+	files := tlsFiles(cfg)
+	stamps := stampFiles(files)
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &reloadingTransport{cfg: cfg, files: files, stamps: stamps, transport: transport}, nil
+}
+
+func (t *reloadingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.current().RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the current transport.
+func (t *reloadingTransport) CloseIdleConnections() {
+	t.current().CloseIdleConnections()
+}
+
+// current returns the transport built from the current certificate files, reloading them if they
+// changed.
+func (t *reloadingTransport) current() *http.Transport {
+	stamps := stampFiles(t.files)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if slices.Equal(stamps, t.stamps) {
+		return t.transport
+	}
+	t.stamps = stamps
+
+	transport, err := newTransport(t.cfg)
+	if err != nil {
+		logger.Warningf("Unable to reload the TLS certificates, keeping the previous ones: %v", err)
+		return t.transport
+	}
+	logger.Infof("Reloaded the TLS certificates")
+	t.transport.CloseIdleConnections()
+	t.transport = transport
+	return t.transport
+}
@@ -0,0 +1,275 @@
+package pihole
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/external-dns/endpoint"
+	"time"
+)
+
+// testCA is a certificate authority issuing the certificates of the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func (suite *PiholeTestSuite) newTestCA(name string) *testCA {
+	t := suite.T()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate signed by the CA for the names of the template, in PEM.
+func (suite *PiholeTestSuite) issue(ca *testCA, template *x509.Certificate) (certPEM, keyPEM []byte) {
+	t := suite.T()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// serverCertificate issues a server certificate for the given IP addresses and DNS names.
+func (suite *PiholeTestSuite) serverCertificate(ca *testCA, ips []net.IP, names ...string) tls.Certificate {
+	certPEM, keyPEM := suite.issue(ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "pihole"},
+		IPAddresses: ips,
+		DNSNames:    names,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	return cert
+}
+
+// clientCertificate issues a client certificate and writes it along with its key to files.
+func (suite *PiholeTestSuite) clientCertificate(ca *testCA, certFile, keyFile string) {
+	certPEM, keyPEM := suite.issue(ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "external-dns"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	suite.writeFile(certFile, certPEM)
+	suite.writeFile(keyFile, keyPEM)
+}
+
+// writeFile writes a file and moves its modification time forward, so the change is noticed even
+// within the resolution of the file system clock.
+func (suite *PiholeTestSuite) writeFile(file string, data []byte) {
+	t := suite.T()
+	modTime := time.Now()
+	if info, err := os.Stat(file); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tlsServer starts a Pi-hole v6 server with the certificate, which requires a client certificate
+// signed by clientCA when it is set.
+func (suite *PiholeTestSuite) tlsServer(cert tls.Certificate, clientCA *testCA) *httptest.Server {
+	server := httptest.NewUnstartedServer(suite.authedHandler(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(RecordsResponse{
+			Config: RecordsConfig{DNS: DNS{CnameRecords: []string{"test-one.example.io,proxy.example.io"}}},
+		})
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != nil {
+		server.TLS.ClientCAs = x509.NewCertPool()
+		server.TLS.ClientCAs.AddCert(clientCA.cert)
+		server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	server.StartTLS()
+	return server
+}
+
+var loopback = []net.IP{net.IPv4(127, 0, 0, 1)}
+
+func (suite *PiholeTestSuite) TestTLSCustomCABundle() {
+	t := suite.T()
+	ca := suite.newTestCA("internal CA")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	suite.writeFile(caFile, ca.pem)
+	server := suite.tlsServer(suite.serverCertificate(ca, loopback), nil)
+	defer server.Close()
+
+	_, err := newPiholeClient(Config{Server: server.URL, APIVersion: apiVersion6, Password: "password", Retry: fastRetryPolicy})
+	assert.ErrorContains(t, err, "certificate signed by unknown authority")
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		APIVersion: apiVersion6,
+		Password:   "password",
+		TLSCAFile:  caFile,
+		Retry:      fastRetryPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+}
+
+func (suite *PiholeTestSuite) TestTLSServerNameOverride() {
+	t := suite.T()
+	ca := suite.newTestCA("internal CA")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	suite.writeFile(caFile, ca.pem)
+	server := suite.tlsServer(suite.serverCertificate(ca, nil, "pihole.internal"), nil)
+	defer server.Close()
+
+	cfg := Config{Server: server.URL, APIVersion: apiVersion6, Password: "password", TLSCAFile: caFile, Retry: fastRetryPolicy}
+	_, err := newPiholeClient(cfg)
+	assert.ErrorContains(t, err, "127.0.0.1")
+
+	cfg.TLSServerName = "pihole.internal"
+	_, err = newPiholeClient(cfg)
+	assert.Nil(t, err)
+}
+
+func (suite *PiholeTestSuite) TestTLSMinVersion() {
+	t := suite.T()
+	ca := suite.newTestCA("internal CA")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	suite.writeFile(caFile, ca.pem)
+	server := suite.tlsServer(suite.serverCertificate(ca, loopback), nil)
+	server.TLS.MaxVersion = tls.VersionTLS12
+	defer server.Close()
+
+	cfg := Config{Server: server.URL, APIVersion: apiVersion6, Password: "password", TLSCAFile: caFile, Retry: fastRetryPolicy}
+	cfg.TLSMinVersion = "1.2"
+	_, err := newPiholeClient(cfg)
+	assert.Nil(t, err)
+
+	cfg.TLSMinVersion = "1.3"
+	_, err = newPiholeClient(cfg)
+	assert.ErrorContains(t, err, "protocol version")
+}
+
+func (suite *PiholeTestSuite) TestTLSClientCertificate() {
+	t := suite.T()
+	ca := suite.newTestCA("internal CA")
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	suite.writeFile(caFile, ca.pem)
+	suite.clientCertificate(ca, certFile, keyFile)
+	server := suite.tlsServer(suite.serverCertificate(ca, loopback), ca)
+	defer server.Close()
+
+	cfg := Config{Server: server.URL, APIVersion: apiVersion6, Password: "password", TLSCAFile: caFile, Retry: fastRetryPolicy}
+	_, err := newPiholeClient(cfg)
+	assert.NotNil(t, err)
+
+	cfg.TLSCertFile, cfg.TLSKeyFile = certFile, keyFile
+	_, err = newPiholeClient(cfg)
+	assert.Nil(t, err)
+}
+
+func (suite *PiholeTestSuite) TestTLSReloadsChangedCertificates() {
+	t := suite.T()
+	ca, otherCA := suite.newTestCA("internal CA"), suite.newTestCA("other CA")
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	suite.writeFile(caFile, otherCA.pem)
+	suite.clientCertificate(otherCA, certFile, keyFile)
+	server := suite.tlsServer(suite.serverCertificate(ca, loopback), ca)
+	defer server.Close()
+
+	httpClient, err := newHTTPClient(Config{TLSCAFile: caFile, TLSCertFile: certFile, TLSKeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() error {
+		res, err := httpClient.Get(server.URL + "/api/info/version")
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	assert.ErrorContains(t, get(), "certificate signed by unknown authority")
+
+	suite.writeFile(caFile, ca.pem)
+	assert.NotNil(t, get(), "the server still rejects the client certificate")
+
+	suite.clientCertificate(ca, certFile, keyFile)
+	assert.Nil(t, get())
+
+	// A certificate that fails to load keeps the previous one in use.
+	suite.writeFile(keyFile, []byte("not a key"))
+	httpClient.CloseIdleConnections()
+	assert.Nil(t, get())
+}
+
+func (suite *PiholeTestSuite) TestInvalidTLSConfig() {
+	t := suite.T()
+	dir := t.TempDir()
+	emptyFile := filepath.Join(dir, "empty.pem")
+	suite.writeFile(emptyFile, nil)
+
+	for name, cfg := range map[string]Config{
+		"unknown version":  {TLSMinVersion: "1.4"},
+		"missing CA file":  {TLSCAFile: filepath.Join(dir, "missing.pem")},
+		"empty CA file":    {TLSCAFile: emptyFile},
+		"certificate only": {TLSCertFile: emptyFile},
+		"invalid key pair": {TLSCertFile: emptyFile, TLSKeyFile: emptyFile},
+	} {
+		cfg.Server = "https://pihole.invalid"
+		_, err := NewPiholeProvider(cfg)
+		assert.ErrorIs(t, err, ErrInvalidTLSConfig, name)
+	}
+}
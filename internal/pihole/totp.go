@@ -0,0 +1,53 @@
+package pihole
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Pi-hole accepts the 6 digit, 30 second time step, HMAC-SHA1 codes of RFC 6238, as generated by
+// common authenticator apps from the secret shown when enabling two-factor authentication.
+const (
+	totpStep   = 30 * time.Second
+	totpModulo = 1_000_000
+)
+
+// totpCode returns the TOTP code of a base32 encoded secret at the given time.
+func totpCode(secret string, at time.Time) (int, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/int64(totpStep/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return int(value % totpModulo), nil
+}
+
+// decodeTOTPSecret decodes a base32 secret, ignoring case, spaces, dashes and padding, as secrets
+// are often displayed grouped for readability.
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	cleaned := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(secret))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(cleaned)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("invalid TOTP secret, expected a base32 encoded secret")
+	}
+	return key, nil
+}
+
+// untilNextTOTPStep returns how long it takes until the time step following the given time starts.
+func untilNextTOTPStep(now time.Time) time.Duration {
+	return totpStep - time.Duration(now.UnixNano()%int64(totpStep))
+}
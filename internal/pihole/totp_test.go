@@ -0,0 +1,149 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 secret of the RFC 6238 test vectors, "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func (suite *PiholeTestSuite) TestTOTPCode() {
+	t := suite.T()
+	for at, expected := range map[int64]int{
+		59:          287082,
+		1111111109:  81804,
+		1111111111:  50471,
+		1234567890:  5924,
+		2000000000:  279037,
+		20000000000: 353130,
+	} {
+		code, err := totpCode(rfc6238Secret, time.Unix(at, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code, "time %d", at)
+	}
+
+	code, err := totpCode("gezd gnbv-gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, 287082, code)
+
+	_, err = totpCode("not base32!", time.Unix(59, 0))
+	assert.NotNil(t, err)
+}
+
+func (suite *PiholeTestSuite) TestUntilNextTOTPStep() {
+	t := suite.T()
+	assert.Equal(t, 30*time.Second, untilNextTOTPStep(time.Unix(60, 0)))
+	assert.Equal(t, 1*time.Second, untilNextTOTPStep(time.Unix(89, 0)))
+}
+
+// totpServer accepts logins with the given code, reporting codes as missing or rejected otherwise.
+func (suite *PiholeTestSuite) totpServer(accepted int, logins *[]LoginRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/info/version":
+			_ = json.NewEncoder(w).Encode(VersionResponse{})
+		case "/api/auth":
+			var request LoginRequest
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			*logins = append(*logins, request)
+			switch {
+			case request.TOTP == nil:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":{"key":"bad_request","message":"No 2FA token found in JSON payload","hint":null}}`))
+			case *request.TOTP != accepted:
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":{"key":"unauthorized","message":"Invalid 2FA token","hint":null}}`))
+			default:
+				_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Sid: "sid", Valid: true}})
+			}
+		}
+	}
+}
+
+func (suite *PiholeTestSuite) TestLoginWithTOTP() {
+	t := suite.T()
+	var logins []LoginRequest
+	now := time.Unix(1111111109, 0)
+	server := suite.newTestServer(suite.totpServer(81804, &logins))
+	defer server.Close()
+
+	p := &piholeClient{
+		cfg:        Config{Server: server.URL},
+		httpClient: &http.Client{},
+		auth:       &passwordAuth{password: "password", totpSecret: rfc6238Secret},
+		now:        func() time.Time { return now },
+		sleep:      sleepContext,
+	}
+
+	assert.Nil(t, p.retrieveNewToken(context.Background()))
+	assert.Len(t, logins, 1)
+	assert.Equal(t, "sid", p.session.Sid)
+}
+
+func (suite *PiholeTestSuite) TestLoginRetriesTOTPAtNextStep() {
+	t := suite.T()
+	var logins []LoginRequest
+	// The server clock is a step ahead, so only the code of the next step is accepted.
+	now := time.Unix(1111111109, 0)
+	next, _ := totpCode(rfc6238Secret, now.Add(totpStep))
+	server := suite.newTestServer(suite.totpServer(next, &logins))
+	defer server.Close()
+
+	var waited time.Duration
+	p := &piholeClient{
+		cfg:        Config{Server: server.URL},
+		httpClient: &http.Client{},
+		auth:       &passwordAuth{password: "password", totpSecret: rfc6238Secret},
+		now:        func() time.Time { return now },
+		sleep: func(_ context.Context, delay time.Duration) error {
+			waited += delay
+			now = now.Add(delay)
+			return nil
+		},
+	}
+
+	assert.Nil(t, p.retrieveNewToken(context.Background()))
+	assert.Len(t, logins, 2)
+	assert.Equal(t, time.Second, waited)
+	assert.Equal(t, next, *logins[1].TOTP)
+}
+
+func (suite *PiholeTestSuite) TestLoginFailsWithRejectedTOTP() {
+	t := suite.T()
+	var logins []LoginRequest
+	server := suite.newTestServer(suite.totpServer(-1, &logins))
+	defer server.Close()
+
+	p := &piholeClient{
+		cfg:        Config{Server: server.URL},
+		httpClient: &http.Client{},
+		auth:       &passwordAuth{password: "password", totpSecret: rfc6238Secret},
+		now:        time.Now,
+		sleep:      func(context.Context, time.Duration) error { return nil },
+	}
+
+	err := p.retrieveNewToken(context.Background())
+	assert.ErrorIs(t, err, ErrTOTPRejected)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Len(t, logins, 2)
+}
+
+func (suite *PiholeTestSuite) TestLoginRequiresTOTPSecret() {
+	t := suite.T()
+	var logins []LoginRequest
+	server := suite.newTestServer(suite.totpServer(0, &logins))
+	defer server.Close()
+
+	_, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	assert.ErrorIs(t, err, ErrTOTPRequired)
+	assert.ErrorContains(t, err, "PIHOLE_TOTP_SECRET")
+	assert.Len(t, logins, 1)
+}
@@ -0,0 +1,131 @@
+package pihole
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultAPIPath is where Pi-hole v6 serves its REST API.
+const defaultAPIPath = "/api"
+
+// TransportConfig controls the HTTP connections to the Pi-hole server. Zero durations and sizes
+// leave the limits of the Go HTTP client in place.
+type TransportConfig struct {
+	Timeout               time.Duration     `env:"PIHOLE_HTTP_TIMEOUT" envDefault:"30s"`
+	DialTimeout           time.Duration     `env:"PIHOLE_HTTP_DIAL_TIMEOUT" envDefault:"10s"`
+	TLSHandshakeTimeout   time.Duration     `env:"PIHOLE_HTTP_TLS_HANDSHAKE_TIMEOUT" envDefault:"10s"`
+	ResponseHeaderTimeout time.Duration     `env:"PIHOLE_HTTP_RESPONSE_HEADER_TIMEOUT" envDefault:"20s"`
+	KeepAlive             time.Duration     `env:"PIHOLE_HTTP_KEEP_ALIVE" envDefault:"30s"`
+	IdleConnTimeout       time.Duration     `env:"PIHOLE_HTTP_IDLE_CONN_TIMEOUT" envDefault:"90s"`
+	MaxIdleConns          int               `env:"PIHOLE_HTTP_MAX_IDLE_CONNS" envDefault:"4"`
+	MaxConnsPerHost       int               `env:"PIHOLE_HTTP_MAX_CONNS_PER_HOST" envDefault:"0"`
+	Proxy                 string            `env:"PIHOLE_HTTP_PROXY" envDefault:""`
+	Headers               map[string]string `env:"PIHOLE_HTTP_HEADERS" envKeyValSeparator:":"`
+}
+
+// apiURL returns the URL of a Pi-hole v6 API path, below the configured API path of the server.
+func apiURL(cfg Config, path string) string {
+	apiPath := cfg.APIPath
+	if apiPath == "" {
+		apiPath = defaultAPIPath
+	}
+	if apiPath = strings.Trim(apiPath, "/"); apiPath != "" {
+		apiPath = "/" + apiPath
+	}
+	return strings.TrimSuffix(cfg.Server, "/") + apiPath + path
+}
+
+// newHTTPClient creates the HTTP client used to reach the Pi-hole server. When certificate files
+// are configured, they are reloaded as they change.
+func newHTTPClient(cfg Config) (*http.Client, error) {
+	var transport http.RoundTripper
+	if len(tlsFiles(cfg)) > 0 {
+		reloading, err := newReloadingTransport(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport = reloading
+	} else {
+		plain, err := newTransport(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport = plain
+	}
+
+	if len(cfg.Transport.Headers) > 0 {
+		transport = newHeaderTransport(cfg.Transport.Headers, transport)
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.Transport.Timeout}, nil
+}
+
+// newTransport creates the transport of the HTTP client. Requests go through the proxy given in
+// the configuration, or else through the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables.
+func newTransport(cfg Config) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.Transport.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Transport.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("%w: invalid proxy URL %q", ErrInvalidTransportConfig, cfg.Transport.Proxy)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Transport.DialTimeout, KeepAlive: cfg.Transport.KeepAlive}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.Transport.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.Transport.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.Transport.IdleConnTimeout,
+		MaxIdleConns:          cfg.Transport.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.Transport.MaxIdleConns,
+		MaxConnsPerHost:       cfg.Transport.MaxConnsPerHost,
+		ForceAttemptHTTP2:     true,
+	}, nil
+}
+
+// headerTransport adds static headers, such as the credentials of a reverse proxy in front of the
+// Pi-hole, to every request.
+type headerTransport struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func newHeaderTransport(headers map[string]string, next http.RoundTripper) *headerTransport {
+	t := &headerTransport{headers: http.Header{}, next: next}
+	for name, value := range headers {
+		t.headers.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return t
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		if name == "Host" {
+			req.Host = values[0]
+			continue
+		}
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport.
+func (t *headerTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
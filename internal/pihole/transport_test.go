@@ -0,0 +1,162 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cnameRecords answers with a single CNAME record.
+func cnameRecords(w http.ResponseWriter, r *http.Request) {
+	_ = json.NewEncoder(w).Encode(RecordsResponse{
+		Config: RecordsConfig{DNS: DNS{CnameRecords: []string{"test-one.example.io,proxy.example.io"}}},
+	})
+}
+
+func (suite *PiholeTestSuite) TestAPIURL() {
+	t := suite.T()
+
+	for _, tc := range []struct {
+		server, apiPath, want string
+	}{
+		{"http://pi.hole", "", "http://pi.hole/api/auth"},
+		{"http://pi.hole/", "/api", "http://pi.hole/api/auth"},
+		{"http://proxy.lan", "/pihole/api/", "http://proxy.lan/pihole/api/auth"},
+		{"http://proxy.lan/pihole", "api", "http://proxy.lan/pihole/api/auth"},
+		{"http://proxy.lan", "/", "http://proxy.lan/auth"},
+	} {
+		assert.Equal(t, tc.want, apiURL(Config{Server: tc.server, APIPath: tc.apiPath}, "/auth"))
+	}
+}
+
+func (suite *PiholeTestSuite) TestAPIPathPrefix() {
+	t := suite.T()
+	mux := http.NewServeMux()
+	mux.Handle("/pihole/", http.StripPrefix("/pihole", suite.authedHandler(cnameRecords)))
+	server := suite.newTestServer(mux.ServeHTTP)
+	defer server.Close()
+
+	for _, cfg := range []Config{
+		{Server: server.URL, APIPath: "/pihole/api"},
+		{Server: server.URL + "/pihole/"},
+	} {
+		cfg.Password = "password"
+		client, err := newPiholeClient(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+		assert.Nil(t, err)
+		assert.Len(t, records, 1)
+	}
+}
+
+func (suite *PiholeTestSuite) TestStaticHeaders() {
+	t := suite.T()
+	var mu sync.Mutex
+	var paths []string
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Basic dXNlcjpwYXNz" || r.Host != "pihole.lan" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		suite.authedHandler(cnameRecords)(w, r)
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		APIVersion: apiVersionAuto,
+		Password:   "password",
+		Transport: TransportConfig{Headers: map[string]string{
+			"authorization": "Basic dXNlcjpwYXNz",
+			"Host":          "pihole.lan",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/api/info/version", "/api/auth", "/api/info/version", "/api/config"}, paths)
+}
+
+func (suite *PiholeTestSuite) TestProxy() {
+	t := suite.T()
+	var hosts []string
+	proxy := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		suite.authedHandler(cnameRecords)(w, r)
+	})
+	defer proxy.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:    "http://pihole.invalid",
+		Password:  "password",
+		Transport: TransportConfig{Proxy: proxy.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, hosts)
+	for _, host := range hosts {
+		assert.Equal(t, "pihole.invalid", host)
+	}
+}
+
+func (suite *PiholeTestSuite) TestRequestTimeout() {
+	t := suite.T()
+	var calls atomic.Int32
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	defer server.Close()
+
+	for _, transport := range []TransportConfig{
+		{Timeout: 20 * time.Millisecond},
+		{ResponseHeaderTimeout: 20 * time.Millisecond},
+	} {
+		calls.Store(0)
+		client, err := newPiholeClient(Config{
+			Server:    server.URL,
+			Password:  "password",
+			Retry:     fastRetryPolicy,
+			Transport: transport,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		_, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeCNAME)
+
+		assert.ErrorContains(t, err, "giving up after 3 attempts")
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Less(t, time.Since(start), time.Second)
+	}
+}
+
+func (suite *PiholeTestSuite) TestInvalidTransportConfig() {
+	t := suite.T()
+
+	for _, proxy := range []string{"://proxy", "proxy.lan:3128"} {
+		_, err := NewPiholeProvider(Config{Server: "http://pihole.invalid", Transport: TransportConfig{Proxy: proxy}})
+		assert.ErrorIs(t, err, ErrInvalidTransportConfig, proxy)
+	}
+}
@@ -0,0 +1,144 @@
+package pihole
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func (suite *PiholeTestSuite) TestListTxtEndpoints() {
+	t := suite.T()
+	lines := []string{
+		"txt-record=admin.example.io,unmanaged",
+		dnsmasqBlockBegin,
+		`txt-record=test-one.example.io,"heritage=external-dns,external-dns/owner=default"`,
+		"txt-record=test-two.example.io,plain",
+		"txt-record=test-two.example.io,other",
+		dnsmasqBlockEnd,
+	}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeTXT)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "test-one.example.io", records[0].DNSName)
+	assert.Equal(t, endpoint.Targets{`"heritage=external-dns,external-dns/owner=default"`}, records[0].Targets)
+	assert.Equal(t, "test-two.example.io", records[1].DNSName)
+	assert.Equal(t, endpoint.Targets{"plain", "other"}, records[1].Targets)
+}
+
+func (suite *PiholeTestSuite) TestCreateTxtRecord() {
+	t := suite.T()
+	lines := []string{"txt-record=admin.example.io,unmanaged"}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{`"heritage=external-dns,external-dns/owner=default"`, "a, b"},
+		DNSName:    "test-one.example.io",
+		RecordType: endpoint.RecordTypeTXT,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"txt-record=admin.example.io,unmanaged",
+		dnsmasqBlockBegin,
+		`txt-record=test-one.example.io,"heritage=external-dns,external-dns/owner=default"`,
+		`txt-record=test-one.example.io,a", "b`,
+		dnsmasqBlockEnd,
+	}, lines)
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeTXT)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, endpoint.Targets{`"heritage=external-dns,external-dns/owner=default"`, "a, b"}, records[0].Targets)
+}
+
+func (suite *PiholeTestSuite) TestTxtQuotingRoundTrip() {
+	t := suite.T()
+
+	for _, text := range []string{
+		"plain",
+		"v=spf1 include:x ~all",
+		`say "hi" twice`,
+		`back\slash, comma`,
+		`"heritage=external-dns,external-dns/owner=default"`,
+		`"a`,
+		`a"`,
+	} {
+		line, err := formatDnsmasqLine(endpoint.RecordTypeTXT, "test-one.example.io", text)
+		if !assert.Nil(t, err, text) {
+			continue
+		}
+		rtype, host, err := parseDnsmasqLine(line)
+		assert.Nil(t, err, line)
+		assert.Equal(t, endpoint.RecordTypeTXT, rtype)
+		assert.Equal(t, text, host.target, line)
+	}
+
+	_, err := formatDnsmasqLine(endpoint.RecordTypeTXT, "test-one.example.io", " padded ")
+	assert.NotNil(t, err)
+}
+
+func (suite *PiholeTestSuite) TestDeleteTxtRecord() {
+	t := suite.T()
+	lines := []string{
+		dnsmasqBlockBegin,
+		"txt-record=test-one.example.io,plain",
+		dnsmasqBlockEnd,
+		"txt-record=test-one.example.io,plain",
+	}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.deleteRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"plain"},
+		DNSName:    "test-one.example.io",
+		RecordType: endpoint.RecordTypeTXT,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"txt-record=test-one.example.io,plain"}, lines)
+}
+
+func (suite *PiholeTestSuite) TestCreateTxtRecordRejectsNewlines() {
+	t := suite.T()
+	lines := []string{}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"text\naddress=/#/0.0.0.0"},
+		DNSName:    "test-one.example.io",
+		RecordType: endpoint.RecordTypeTXT,
+	})
+
+	assert.NotNil(t, err)
+	assert.Empty(t, lines)
+}
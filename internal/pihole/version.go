@@ -0,0 +1,112 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"github.com/scaleway/scaleway-sdk-go/logger"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const apiVersionAuto = "auto"
+
+// minimumCnameTTLVersion is the first FTL release accepting a TTL field in dns.cnameRecords.
+var minimumCnameTTLVersion = semver{6, 1, 0}
+
+// Capabilities lists the optional features supported by the Pi-hole server.
+type Capabilities struct {
+	// CnameTTL is set when dns.cnameRecords accepts a TTL field.
+	CnameTTL bool
+	// DnsmasqLines is set when misc.dnsmasq_lines can hold the records Pi-hole has no native
+	// support for.
+	DnsmasqLines bool
+}
+
+type semver struct {
+	major, minor, patch int
+}
+
+// parseSemver parses versions such as "v6.0.4" or "5.18", reporting false for development
+// builds that carry a branch name instead.
+func parseSemver(version string) (semver, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".", 3)
+	var numbers [3]int
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return semver{}, false
+		}
+		numbers[i] = number
+	}
+	return semver{numbers[0], numbers[1], numbers[2]}, true
+}
+
+func (v semver) atLeast(o semver) bool {
+	if v.major != o.major {
+		return v.major > o.major
+	}
+	if v.minor != o.minor {
+		return v.minor > o.minor
+	}
+	return v.patch >= o.patch
+}
+
+// capabilitiesFor checks that the server version is supported by the given API generation and
+// returns the features it offers. Versions that cannot be parsed are assumed to be recent.
+func capabilitiesFor(apiVersion string, version ServerVersion) (Capabilities, error) {
+	switch apiVersion {
+	case apiVersion5:
+		core, ok := parseSemver(version.Core)
+		if !ok {
+			logger.Warningf("Unable to parse Pi-hole core version %q", version.Core)
+		} else if core.major != 5 {
+			return Capabilities{}, fmt.Errorf("%w: Pi-hole core %s through the v5 API", ErrUnsupportedServerVersion, version.Core)
+		}
+		return Capabilities{}, nil
+	case apiVersion6:
+		ftl, ok := parseSemver(version.FTL)
+		if !ok {
+			logger.Warningf("Unable to parse Pi-hole FTL version %q, assuming all features are available", version.FTL)
+			return Capabilities{CnameTTL: true, DnsmasqLines: true}, nil
+		}
+		if ftl.major != 6 {
+			return Capabilities{}, fmt.Errorf("%w: Pi-hole FTL %s through the v6 API", ErrUnsupportedServerVersion, version.FTL)
+		}
+		return Capabilities{
+			CnameTTL:     ftl.atLeast(minimumCnameTTLVersion),
+			DnsmasqLines: true,
+		}, nil
+	}
+	return Capabilities{}, fmt.Errorf("%w: %q", ErrUnsupportedAPIVersion, apiVersion)
+}
+
+// recordServerVersion logs the detected server and exposes it in metrics.
+func recordServerVersion(apiVersion string, version ServerVersion, caps Capabilities) {
+	logger.Infof("Connected to Pi-hole through the v%s API (core %s, web %s, FTL %s, CNAME TTL: %t, dnsmasq lines: %t)",
+		apiVersion, version.Core, version.Web, version.FTL, caps.CnameTTL, caps.DnsmasqLines)
+	serverInfo.Reset()
+	serverInfo.WithLabelValues(apiVersion, version.Core, version.Web, version.FTL).Set(1)
+}
+
+// probeAPIVersion detects the API generation of the server: Pi-hole v6 serves its REST API under
+// /api, whereas Pi-hole v5 only knows admin/api.php and answers 404 there.
+func probeAPIVersion(ctx context.Context, httpClient *http.Client, cfg Config) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL(cfg, "/info/version"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		logger.Debugf("Pi-hole does not serve %s, falling back to the v5 API", apiURL(cfg, ""))
+		return apiVersion5, nil
+	}
+	return apiVersion6, nil
+}
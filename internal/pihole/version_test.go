@@ -0,0 +1,131 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// versionServer serves a Pi-hole v6 API reporting the given FTL version, recording the paths of
+// every other request.
+func (suite *PiholeTestSuite) versionServer(ftl string, paths *[]string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/auth":
+			_ = json.NewEncoder(w).Encode(LoginResponse{Session: Session{Sid: "sid", Valid: true}})
+		case "/api/info/version":
+			_ = json.NewEncoder(w).Encode(VersionResponse{
+				Version: ComponentVersions{
+					Core: ComponentVersion{Local: LocalVersion{Version: "v6.0.4"}},
+					Web:  ComponentVersion{Local: LocalVersion{Version: "v6.0.1"}},
+					FTL:  ComponentVersion{Local: LocalVersion{Version: ftl}},
+				},
+			})
+		default:
+			*paths = append(*paths, r.URL.Path)
+		}
+	}
+}
+
+func (suite *PiholeTestSuite) TestParseSemver() {
+	t := suite.T()
+
+	version, ok := parseSemver("v6.0.4")
+	assert.True(t, ok)
+	assert.Equal(t, semver{6, 0, 4}, version)
+
+	version, ok = parseSemver("5.18")
+	assert.True(t, ok)
+	assert.Equal(t, semver{5, 18, 0}, version)
+
+	_, ok = parseSemver("vDev-1a2b3c")
+	assert.False(t, ok)
+
+	assert.True(t, semver{6, 1, 0}.atLeast(semver{6, 1, 0}))
+	assert.True(t, semver{6, 2, 0}.atLeast(semver{6, 1, 3}))
+	assert.False(t, semver{6, 0, 9}.atLeast(semver{6, 1, 0}))
+}
+
+func (suite *PiholeTestSuite) TestCapabilitiesFor() {
+	t := suite.T()
+
+	caps, err := capabilitiesFor(apiVersion6, ServerVersion{FTL: "v6.0.2"})
+	assert.Nil(t, err)
+	assert.Equal(t, Capabilities{DnsmasqLines: true}, caps)
+
+	caps, err = capabilitiesFor(apiVersion6, ServerVersion{FTL: "v6.1.0"})
+	assert.Nil(t, err)
+	assert.Equal(t, Capabilities{CnameTTL: true, DnsmasqLines: true}, caps)
+
+	caps, err = capabilitiesFor(apiVersion5, ServerVersion{Core: "v5.18.2"})
+	assert.Nil(t, err)
+	assert.Equal(t, Capabilities{}, caps)
+
+	_, err = capabilitiesFor(apiVersion6, ServerVersion{FTL: "v7.0.0"})
+	assert.ErrorIs(t, err, ErrUnsupportedServerVersion)
+
+	_, err = capabilitiesFor(apiVersion5, ServerVersion{Core: "v4.4"})
+	assert.ErrorIs(t, err, ErrUnsupportedServerVersion)
+}
+
+func (suite *PiholeTestSuite) TestDetectVersion() {
+	t := suite.T()
+	var paths []string
+	server := suite.newTestServer(suite.versionServer("v6.0.2", &paths))
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		Password:   "password",
+		APIVersion: apiVersionAuto,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ServerVersion{Core: "v6.0.4", Web: "v6.0.1", FTL: "v6.0.2"}, client.(*piholeClient).version)
+	assert.Equal(t, Capabilities{DnsmasqLines: true}, client.capabilities())
+
+	// FTL v6.0 does not store CNAME TTLs, so the TTL is left out of the entry.
+	_ = client.createRecord(context.Background(), endpoint.NewEndpointWithTTL("test-one.example.io", endpoint.RecordTypeCNAME, 300, "proxy.example.io"))
+	assert.Equal(t, []string{"/api/config/dns/cnameRecords/test-one.example.io,proxy.example.io"}, paths)
+}
+
+func (suite *PiholeTestSuite) TestRefuseUnsupportedVersion() {
+	t := suite.T()
+	server := suite.newTestServer(suite.versionServer("v7.0.0", &[]string{}))
+	defer server.Close()
+
+	_, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	assert.ErrorIs(t, err, ErrUnsupportedServerVersion)
+}
+
+func (suite *PiholeTestSuite) TestDetectV5API() {
+	t := suite.T()
+	v5 := suite.v5Server(nil, nil, nil)
+	defer v5.Close()
+	server := suite.newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/api.php" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		v5.Config.Handler.ServeHTTP(w, r)
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:     server.URL,
+		Password:   "password",
+		APIVersion: apiVersionAuto,
+	})
+
+	assert.Nil(t, err)
+	assert.IsType(t, &piholeClientV5{}, client)
+	assert.Equal(t, "v5.18.2", client.(*piholeClientV5).version.Core)
+}
@@ -0,0 +1,96 @@
+package pihole
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func (suite *PiholeTestSuite) TestListWildcardEndpoints() {
+	t := suite.T()
+	server := suite.authedServer(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(RecordsResponse{
+			Config: RecordsConfig{
+				DNS: DNS{
+					Hosts: []string{"10.0.0.5 nas.example.lan"},
+				},
+				Misc: Misc{
+					DnsmasqLines: []string{
+						"address=/unmanaged.example.lan/10.0.0.1",
+						dnsmasqBlockBegin,
+						"address=/apps.example.lan/10.0.0.10",
+						"address=/apps.example.lan/b29f::10",
+						dnsmasqBlockEnd,
+					},
+				},
+			},
+		})
+	})
+	defer server.Close()
+
+	client, err := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeA)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "nas.example.lan", records[0].DNSName)
+	assert.Equal(t, "*.apps.example.lan", records[1].DNSName)
+	assert.Equal(t, endpoint.Targets{"10.0.0.10"}, records[1].Targets)
+
+	records, err = client.listRecordsOfTypes(context.Background(), endpoint.RecordTypeAAAA)
+
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "*.apps.example.lan", records[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"b29f::10"}, records[0].Targets)
+}
+
+func (suite *PiholeTestSuite) TestCreateWildcardRecord() {
+	t := suite.T()
+	lines := []string{}
+	server := suite.dnsmasqServer(&lines)
+	defer server.Close()
+
+	client, _ := newPiholeClient(Config{
+		Server:   server.URL,
+		Password: "password",
+	})
+
+	err := client.createRecord(context.Background(), &endpoint.Endpoint{
+		Targets:    []string{"10.0.0.10"},
+		DNSName:    "*.apps.example.lan",
+		RecordType: endpoint.RecordTypeA,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		dnsmasqBlockBegin,
+		"address=/apps.example.lan/10.0.0.10",
+		dnsmasqBlockEnd,
+	}, lines)
+}
+
+func (suite *PiholeTestSuite) TestApplyChangesSkipsPtrForWildcards() {
+	t := suite.T()
+	api := &fakeApi{}
+	p := &PiholeProvider{api: api, cfg: Config{ManagePTR: true}}
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("*.apps.example.lan", endpoint.RecordTypeA, "10.0.0.10"),
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, api.created, 1)
+}
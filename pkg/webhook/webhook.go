@@ -25,12 +25,40 @@ type Webhook struct {
 	provider provider.Provider
 }
 
+// readinessChecker is implemented by providers that cannot serve requests until they are connected
+// to their backend.
+type readinessChecker interface {
+	Ready() bool
+}
+
 // New creates a new instance of the Webhook
 func New(provider provider.Provider) *Webhook {
 	p := Webhook{provider: provider}
 	return &p
 }
 
+// Ready reports whether the provider is able to serve requests.
+func (p *Webhook) Ready() bool {
+	checker, ok := p.provider.(readinessChecker)
+	return !ok || checker.Ready()
+}
+
+// readyCheck answers with 503 Service Unavailable while the provider is not ready, so external-dns
+// retries the request later.
+func (p *Webhook) readyCheck(w http.ResponseWriter, r *http.Request) error {
+	if p.Ready() {
+		return nil
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypePlaintext)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	err := fmt.Errorf("provider is not ready")
+	if _, writeErr := fmt.Fprint(w, err.Error()); writeErr != nil {
+		requestLog(r).With(zap.Error(writeErr)).Fatal("error writing error message to response writer")
+	}
+	return err
+}
+
 func (p *Webhook) contentTypeHeaderCheck(w http.ResponseWriter, r *http.Request) error {
 	return p.headerCheck(true, w, r)
 }
@@ -95,6 +123,10 @@ func (p *Webhook) Records(w http.ResponseWriter, r *http.Request) {
 		requestLog(r).With(zap.Error(err)).Error("accept header check failed")
 		return
 	}
+	if err := p.readyCheck(w, r); err != nil {
+		requestLog(r).With(zap.Error(err)).Warn("unable to get records")
+		return
+	}
 
 	ctx := r.Context()
 	records, err := p.provider.Records(ctx)
@@ -120,6 +152,10 @@ func (p *Webhook) ApplyChanges(w http.ResponseWriter, r *http.Request) {
 		requestLog(r).With(zap.Error(err)).Error("content type header check failed")
 		return
 	}
+	if err := p.readyCheck(w, r); err != nil {
+		requestLog(r).With(zap.Error(err)).Warn("unable to apply changes")
+		return
+	}
 
 	var changes plan.Changes
 	ctx := r.Context()
@@ -160,6 +196,10 @@ func (p *Webhook) AdjustEndpoints(w http.ResponseWriter, r *http.Request) {
 		log.Error("accept header check failed", zap.String("req_method", r.Method), zap.String("req_path", r.URL.Path))
 		return
 	}
+	if err := p.readyCheck(w, r); err != nil {
+		requestLog(r).With(zap.Error(err)).Warn("unable to adjust endpoints")
+		return
+	}
 
 	var pve []*endpoint.Endpoint
 	if err := json.NewDecoder(r.Body).Decode(&pve); err != nil {